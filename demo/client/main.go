@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"sigauth/sigauth"
 	"strings"
 	"time"
@@ -21,7 +20,10 @@ var (
 )
 
 func sendRequest(r *http.Request) {
-	client := new(http.Client)
+	sendRequestWithClient(new(http.Client), r)
+}
+
+func sendRequestWithClient(client *http.Client, r *http.Request) {
 	res, err := client.Do(r)
 	if err != nil {
 		fmt.Printf("sendRequest, err=%v", err)
@@ -106,14 +108,21 @@ func main() {
 		r.Header.Set(sigauth.HttpHeaderContentType, sigauth.ContentTypeJson)
 		setSign(r, "key", SigAuthKeySecret[1][1], time.Now().Unix())
 	})
-	// post json 将 auth 放到 参数 ~auth, 注意，如果要这个方式要成功的话， server 那边要将 timeCheck 关闭，不然 sign 会一直变
-	// auth := sigauth.BuildAuthorizationHeader(sigauth.Authorization{
-	// 	Key:       "testkey1",
-	// 	Sign:      "fb954c9a49c0914c9c4d7af2fc4c0d0b20e7d6060ebd37dedb0b03edac138694",
-	// 	Timestamp: 1661934251,
-	// })
-	auth := "SIG-AUTH Key=testkey1, Sign=fb954c9a49c0914c9c4d7af2fc4c0d0b20e7d6060ebd37dedb0b03edac138694, Timestamp=1661934251"
-	tryRequest(http.MethodPost, setUrl("sigauth/hello", fmt.Sprintf("msg=123456&~auth=%s", url.QueryEscape(auth))), strings.NewReader(`{"x":"1","Y":2}`), "", func(r *http.Request) {
-		r.Header.Set(sigauth.HttpHeaderContentType, sigauth.ContentTypeJson)
-	})
+	// 生成预签名的链接，签名放在参数 ~auth 上，可直接分享给他人使用，10 分钟后失效
+	link, err := sigauth.PresignURL(http.MethodGet, setUrl("sigauth/hello", "msg=presigned"), SigAuthKeySecret[0][0], SigAuthKeySecret[0][1], 10*time.Minute)
+	if err != nil {
+		fmt.Println("presign err:", err)
+	} else {
+		tryRequest(http.MethodGet, link, nil, "", nil)
+	}
+	// ======== 使用 sigauth.Transport，由 http.Client 自动签名 ==========
+	signClient := &http.Client{
+		Transport: &sigauth.Transport{
+			AccessKey: SigAuthKeySecret[0][0],
+			Secret:    SigAuthKeySecret[0][1],
+		},
+	}
+	r, _ := http.NewRequest(http.MethodPost, setUrl("sigauth/hello", "msg=transport"), strings.NewReader(`{"x":"1","Y":2}`))
+	r.Header.Set(sigauth.HttpHeaderContentType, sigauth.ContentTypeJson)
+	sendRequestWithClient(signClient, r)
 }
@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"encoding/base32"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -16,11 +17,8 @@ import (
 var (
 	//go:embed static/client.html
 	_clientDemoPage string
-	// 设置各个组的 key 和 secret
-	SigAuthKeySecret = [][]string{
-		{"testkey1", "secret1"},
-		{"testkey2", "secret2"},
-	}
+	// 保存各个组的 key 和 secret 的文件，修改后自动重新加载
+	keysFile = flag.String("keys", "demo/server/keys.yaml", "key and secret file")
 )
 
 // 服务端返回标准格式
@@ -133,24 +131,17 @@ func htmlDemoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(_clientDemoPage))
 }
 
-// 验签
-func verifySignature(r *http.Request) {
-	// 设置获取 secret 的 filter
-	secretFinder := func(accessKey string) string {
-		for _, keySec := range SigAuthKeySecret {
-			if accessKey == keySec[0] {
-				return keySec[1]
-			}
-		}
-		return ""
-	}
-	op := sigauth.SigAuthHandlerOption{
-		SecretFinder: secretFinder,
-		// TimeChecker:  sigauth.NoTimeChecker,
-		TimeChecker: sigauth.DefaultTimeChecker,
+// 验签失败时，以标准格式返回错误
+func sigAuthErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	res := NewRes()
+	res.Code = sigauth.ErrorStatusCode(err)
+	res.Message = err.Error()
+	fmt.Printf("%s => %s\n", r.URL, res.Message)
+	// 500 的错误可能包含存储等内部信息，只输出到服务端日志，同 sigauth.DefaultErrorHandler
+	if res.Code == http.StatusInternalServerError {
+		res.Message = http.StatusText(res.Code)
 	}
-	sigAuthResolver := sigauth.NewSigAuthResolver(op.AuthScheme, op.SecretFinder, op.TimeChecker)
-	sigAuthResolver.VerifySignature(r)
+	retrunRes(w, r, res)
 }
 
 // cors 验证中间件, 便于前端 demo 页面可以解耦部署在其他的 web server
@@ -167,24 +158,33 @@ func corsAuthHandler(handler http.HandlerFunc) http.HandlerFunc {
 }
 
 // sigauth 验证中间件
-func sigAuthHandler(handler http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		res := NewRes()
-		// 捕获错误
-		defer func() {
-			if err := recover(); err != nil {
-				res.Code = 400
-				res.Message = err.(string)
-				fmt.Printf("%s => %s\n", r.URL, res.Message)
-				retrunRes(w, r, res)
-			}
-		}()
-		// 进行签名验证
-		verifySignature(r)
-		handler(w, r)
+var sigAuthMiddleware func(http.Handler) http.Handler
+
+func initSigAuth() {
+	// 从文件读取 key 和 secret
+	store, err := sigauth.NewFileKeyStore(*keysFile, sigauth.FileKeyStoreOption{})
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	sigAuthMiddleware = sigauth.Middleware(sigauth.SigAuthHandlerOption{
+		SecretFinder: store.Find,
+		// TimeChecker:  sigauth.NoTimeChecker,
+		TimeChecker: sigauth.DefaultTimeChecker,
+		// 接受 PresignURL 生成的带 Expires 的链接
+		MaxLifetime: sigauth.DefaultMaxLifetime,
+		// 期望的签名等诊断信息只输出到服务端日志，不返回给客户端
+		DebugHook:    sigauth.LogDebugHook(nil),
+		ErrorHandler: sigAuthErrorHandler,
+	})
+}
+
+func sigAuthHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return sigAuthMiddleware(handler).ServeHTTP
 }
 
 func main() {
+	flag.Parse()
+	initSigAuth()
 	initServer()
 }
@@ -2,9 +2,15 @@ module sigauth
 
 go 1.20
 
+require (
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sync v0.11.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
@@ -1,5 +1,7 @@
 package sigauth
 
+import "crypto"
+
 const (
 	// ContentTypeNone 未指定类型。
 	ContentTypeNone = ""
@@ -13,7 +15,7 @@ const (
 	// ContentTypeJavascript 对应 Content-Type: text/javascript 的值。
 	ContentTypeJavascript = "text/javascript"
 
-	// ContentTypePlainText 对应 Content-Type: text/javascript 的值。
+	// ContentTypePlainText 对应 Content-Type: text/plain 的值。
 	ContentTypePlainText = "text/plain"
 
 	// ContentTypeForm 对应 Content-Type: application/x-www-form-urlencoded 的值。
@@ -29,12 +31,38 @@ const (
 
 	// HttpHeaderContentDisposition 对应 HTTP 头中的 Content-Disposition 字段。
 	HttpHeaderContentDisposition = "Content-Disposition"
+
+	// HttpHeaderWWWAuthenticate 对应 HTTP 头中的 WWW-Authenticate 字段，在 401 响应中给出认证方式。
+	HttpHeaderWWWAuthenticate = "WWW-Authenticate"
 )
 
 const (
 	// 默认的签名算法版本，当 Authorization 头没有写 Version 字段时，默认为此版本。
 	DefaultSignVersion = 1
 
+	// 签名算法 v2 ：在 v1 的基础上，待签名串还包含 Authorization 头的 SignedHeaders 指定的 HTTP 头。
+	SignVersion2 = 2
+
+	// 使用 Ed25519 私钥签名的算法，待签名串同 v2 ，签名使用无填充的 base64url 编码。
+	SignVersionEd25519 = 3
+
+	// 使用 ECDSA P-256 私钥签名的算法，对待签名串（同 v2 ）的 SHA-256 摘要签名，
+	// 签名为 ASN.1 DER 格式，使用无填充的 base64url 编码。
+	SignVersionEcdsaP256 = 4
+
+	// 签名算法 v5 ：在 v2 的基础上， QUERY 及表单 body 使用不会产生歧义的规范化格式，
+	// 参数名称和值经百分号编码，以“=”和“&”分隔。 v1 、 v2 中“?a=xy”与“?a=x&b=y”的签名相同，新接入的客户端应使用此版本。
+	SignVersion5 = 5
+
+	// 签名算法 v6 ：不直接使用 secret ，而是使用由 secret 、日期和 [Authorization.Scope] 派生的签名密钥，
+	// 待签名串在 v5 的基础上增加 SCOPE 部分，见 [DeriveSigningKey] 。派生的密钥即使泄露，
+	// 也只能在一天之内用于同一个 Scope ；带 Expires 时， Expires 不能晚于 Timestamp 所在日期的结束（ UTC ）。
+	// 服务端通过 [SigAuthHandlerOption.Scopes] 限定接受的 Scope 。
+	SignVersionDerived = 6
+
+	// 带 Expires 的签名的建议最长有效期，单位为秒，为 7 天，可作为 [SigAuthHandlerOption.MaxLifetime] 的值。
+	DefaultMaxLifetime = 7 * 24 * 3600
+
 	// HTTP Authorization 头的 <scheme> 部分，固定值。
 	DefaultAuthScheme = "SIG-AUTH"
 
@@ -49,18 +77,84 @@ const (
 // SecretFinderFunc 用于获取绑定到指定 accessKey 的 secret 。
 // 若给定的 accessKey 没有绑定，返回空字符串。
 // 若获取过程出错，直接 panic ，其错误处理方式与普通的 API 方法一致。
+// 需要传递 [context.Context] 或返回错误、元数据时，使用 [SecretProvider] 。
 type SecretFinderFunc func(accessKey string) string
 
+// PublicKeyFinderFunc 用于获取绑定到指定 accessKey 的公钥，用于验证非对称签名。
+// 公钥的类型为 [ed25519.PublicKey] 或 [*ecdsa.PublicKey] ，需与签名算法版本匹配。
+// 若给定的 accessKey 没有绑定，返回 nil 。
+type PublicKeyFinderFunc func(accessKey string) crypto.PublicKey
+
 // SigAuthHandlerOption 用于初始化 。
 type SigAuthHandlerOption struct {
 	// 指定 HTTP Authorization 头的 scheme 部分的值。
 	// 若为空，则自动使用默认值 [DefaultAuthScheme] 。
 	AuthScheme string
 
-	// 用于查找签名所需的 secret 。必须提供。
+	// 用于查找签名所需的 secret 。与 SecretProvider 、 PublicKeyFinder 至少提供一个。
+	// 给定 SecretProvider 时此字段被忽略。
 	SecretFinder SecretFinderFunc
 
+	// 用于查找签名所需的 secret 及其元数据。若为 nil ，则使用 SecretFinder 。
+	SecretProvider SecretProvider
+
+	// 用于查找非对称签名（如 [SignVersionEd25519] ）所需的公钥。若为 nil ，则不接受非对称签名。
+	PublicKeyFinder PublicKeyFinderFunc
+
 	// 用于校验签名信息中携带的时间戳的有效性。
 	// 若为 nil ，将自动使用 [DefaultTimeChecker] ；若不需要校验，可给定 [NoTimeChecker] 。
+	// 对于带 Expires 的签名，通过 [TimeCheckerFunc.WithExpiry] 校验。给定 ExpiryTimeChecker 时此字段被忽略。
 	TimeChecker TimeCheckerFunc
+
+	// 用于同时校验时间戳和 Expires 。若为 nil ：给定了 TimeChecker 时，使用其 [TimeCheckerFunc.WithExpiry] ；
+	// 否则使用 [DefaultExpiryTimeChecker] 。
+	ExpiryTimeChecker ExpiryTimeCheckerFunc
+
+	// 带 Expires 的签名的最长有效期，即 Expires 与 Timestamp 之差的上限，单位为秒，如 [DefaultMaxLifetime] 。
+	// 若为 0 ，则不接受带 Expires 的签名（如 [PresignURL] 生成的链接），所有签名均按时间戳的误差范围校验；
+	// 若为负数，则不限制。接受带 Expires 的签名需显式给定此字段，这类签名在有效期内可被重复使用，宜同时配置 NonceStore 。
+	// 配合 NonceStore 使用时，带 Expires 的签名的 nonce 被保留到 Expires ，见 [ExpiringNonceStore] 。
+	MaxLifetime int64
+
+	// 接受的签名算法版本，用于在升级签名算法期间同时接受新旧版本。
+	// 若为空，则接受所有通过 [RegisterSignAlgorithm] 注册的版本。
+	Versions []int
+
+	// 接受的消息认证码算法的名称，用于拒绝强度不足或不符合预期的算法。
+	// 若为空，则接受所有通过 [RegisterMacAlgorithm] 注册的算法。
+	Algorithms []string
+
+	// 接受的 [Authorization.Scope] ，用于 [SignVersionDerived] ，需与签名的 Scope 完全一致。
+	// 若为空，则接受任意 Scope ，此时派生的签名密钥可用于任意 Scope ，业务需自行校验 Scope 。
+	// 签名正确但 Scope 不在其中时，返回 [ErrScopeNotAllowed] 。
+	Scopes []string
+
+	// 请求 body 的最大长度，单位为字节，超过时返回 [ErrBodyTooLarge] 。若为 0 ，则不限制。
+	// 同时作用于签名时读取的 body ，和使用 X-Content-SHA256 时后续流式读取的 body 。
+	MaxBodySize int64
+
+	// 仅用于 [Middleware] 。使用 X-Content-SHA256 头代替 body 签名时，是否在调用后续的 handler 之前
+	// 读取整个 body 并校验摘要，校验失败时交由 ErrorHandler 写入响应。
+	// 默认只在 handler 读到 body 末尾时校验，而如 [encoding/json.Decoder] 等通常不会读到末尾，被篡改的 body 不会被发现。
+	// 为 true 时 body 被读入内存，失去流式处理的好处，宜同时设置 MaxBodySize 。
+	VerifyBodyDigestFirst bool
+
+	// 用于记录已使用过的 nonce ，拒绝重放的请求。若为 nil ，则不校验 nonce 。
+	// 给定时，请求的 Authorization 必须带有 Nonce 。若其未实现 [ExpiringNonceStore] ，则不接受带 Expires 的签名。
+	NonceStore NonceStore
+
+	// 验签失败时调用，用于在服务端记录诊断信息（如期望的签名、待签名串）。可为 nil 。
+	// 诊断信息可被用于伪造签名，只应写入服务端日志，不能返回给客户端。
+	DebugHook DebugHookFunc
+
+	// 仅用于 [Middleware] ，验签失败时用于写入响应。若为 nil ，则使用 [DefaultErrorHandler] 。
+	ErrorHandler ErrorHandlerFunc
+
+	// 可信代理的 IP 或 CIDR ，如“10.0.0.0/8”。仅当请求直接来自其中的地址时，
+	// SignScheme 、 SignHost 才使用 Forwarded 或 X-Forwarded-Proto 、 X-Forwarded-Host 头给出的值。
+	// 若为空，则忽略这些头。格式错误时 panic 。
+	TrustedProxies []string
+
+	// 待签名串的构建选项，如 BodyMethods ，需与客户端一致。
+	SignOption
 }
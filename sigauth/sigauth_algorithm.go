@@ -0,0 +1,65 @@
+package sigauth
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// SignAlgorithm 定义一个版本的签名算法，包括待签名串的构建方式和签名的计算方式。
+// 通过 [RegisterSignAlgorithm] 注册后，以 [Authorization.Version] 选用。实现需是并发安全的。
+type SignAlgorithm interface {
+	// BuildDataToSign 构建待签名串。参数 rewindBody 的含义同 [Sign] ， opt 为签名方和验签方约定的构建选项。
+	// 若失败，返回非 [SignResultType_OK] 的结果和具体原因。
+	BuildDataToSign(r *http.Request, rewindBody bool, auth Authorization, opt SignOption) ([]byte, SignResultType, error)
+
+	// Mac 使用 secret 计算待签名串的签名，返回的签名用于 Authorization 头的 Sign 字段。
+	// 通常应通过 [LookupMacAlgorithm] 按 [Authorization.Algorithm] 选用消息认证码算法。
+	Mac(secret string, data []byte, auth Authorization) (string, error)
+}
+
+var (
+	_signAlgorithmsMu sync.RWMutex
+	_signAlgorithms   = map[int]SignAlgorithm{
+		DefaultSignVersion: standardSignAlgorithm{},
+		SignVersion2:       standardSignAlgorithm{version: SignVersion2, signHeaders: true, markEmptyBody: true, signAnyBody: true, tagBody: true},
+		SignVersion5:       standardSignAlgorithm{version: SignVersion5, signHeaders: true, markEmptyBody: true, signAnyBody: true, tagBody: true, canonicalQuery: true},
+	}
+)
+
+// RegisterSignAlgorithm 将签名算法注册到给定的版本，若版本已存在，则替换之。
+// 通常应在 init 阶段调用。
+func RegisterSignAlgorithm(version int, alg SignAlgorithm) {
+	if version <= 0 {
+		panic("version must be positive")
+	}
+
+	if alg == nil {
+		panic("alg must be provided")
+	}
+
+	_signAlgorithmsMu.Lock()
+	defer _signAlgorithmsMu.Unlock()
+	_signAlgorithms[version] = alg
+}
+
+// LookupSignAlgorithm 返回给定版本的签名算法，若没有注册，返回 nil, false 。
+func LookupSignAlgorithm(version int) (SignAlgorithm, bool) {
+	_signAlgorithmsMu.RLock()
+	defer _signAlgorithmsMu.RUnlock()
+	alg, ok := _signAlgorithms[version]
+	return alg, ok
+}
+
+// SignVersions 返回所有已注册的签名算法版本，升序排列。
+func SignVersions() []int {
+	_signAlgorithmsMu.RLock()
+	defer _signAlgorithmsMu.RUnlock()
+
+	versions := make([]int, 0, len(_signAlgorithms))
+	for v := range _signAlgorithms {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
@@ -0,0 +1,86 @@
+package sigauth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试用的签名算法，在 v1 的基础上将签名转为大写。
+type upperSignAlgorithm struct {
+	standardSignAlgorithm
+}
+
+func (x upperSignAlgorithm) Mac(secret string, data []byte, auth Authorization) (string, error) {
+	return strings.ToUpper(HmacSha256([]byte(secret), data)), nil
+}
+
+func TestRegisterSignAlgorithm(t *testing.T) {
+	const version = 100
+	RegisterSignAlgorithm(version, upperSignAlgorithm{})
+	defer func() {
+		_signAlgorithmsMu.Lock()
+		delete(_signAlgorithms, version)
+		_signAlgorithmsMu.Unlock()
+	}()
+
+	assert.Contains(t, SignVersions(), version)
+
+	r := newRequest("", "/", _requestTypeGet, "")
+	res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Version: version})
+	require.Equal(t, SignResultType_OK, res.Type)
+	assert.Equal(t, "7583E11E7BE21D4B3AA178E8011F18C8D84633403CB0EF62F020EBE121BDC065", res.Sign)
+
+	resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+	auth, err := resolver.Verify(r)
+	require.NoError(t, err)
+	assert.Equal(t, version, auth.Version)
+}
+
+func TestSignWith_unsupportedVersion(t *testing.T) {
+	r := newRequest("", "/", _requestTypeGet, "")
+	res := SignWith(r, false, _secret, Authorization{Timestamp: _timestamp, Version: 999})
+	assert.Equal(t, SignResultType_UnsupportedVersion, res.Type)
+	assert.Error(t, res.Cause)
+}
+
+// 测试验签时限定接受的版本
+func TestSigAuthResolver_versions(t *testing.T) {
+	newSignedRequest := func(version int) *http.Request {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Version: version})
+		require.Equal(t, SignResultType_OK, res.Type)
+		return r
+	}
+
+	t.Run("OnlyV1", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+			Versions:     []int{DefaultSignVersion},
+		})
+
+		_, err := resolver.Verify(newSignedRequest(DefaultSignVersion))
+		require.NoError(t, err)
+
+		_, err = resolver.Verify(newSignedRequest(SignVersion2))
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("Migration", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+			Versions:     []int{DefaultSignVersion, SignVersion2},
+		})
+
+		_, err := resolver.Verify(newSignedRequest(DefaultSignVersion))
+		require.NoError(t, err)
+
+		_, err = resolver.Verify(newSignedRequest(SignVersion2))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,158 @@
+package sigauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+/* 当前文件提供非对称签名算法的实现。 */
+
+// AsymmetricSignAlgorithm 是使用非对称密钥的 [SignAlgorithm] ，签名方持有私钥，验签方只需要公钥。
+// 其 Mac 方法不被使用，签名和验签分别通过 SignWithKey 和 VerifyWithKey 进行。
+type AsymmetricSignAlgorithm interface {
+	SignAlgorithm
+
+	// SignWithKey 使用私钥计算待签名串的签名。
+	SignWithKey(key crypto.Signer, data []byte, auth Authorization) (string, error)
+
+	// VerifyWithKey 使用公钥校验签名，签名不正确时返回 error 。
+	VerifyWithKey(key crypto.PublicKey, data []byte, sign string, auth Authorization) error
+}
+
+func init() {
+	// 待签名串同 v2 ，但 VERSION 部分为各自的版本。
+	RegisterSignAlgorithm(SignVersionEd25519, ed25519SignAlgorithm{standardSignAlgorithm{version: SignVersionEd25519, signHeaders: true, markEmptyBody: true, signAnyBody: true, tagBody: true}})
+	RegisterSignAlgorithm(SignVersionEcdsaP256, ecdsaP256SignAlgorithm{standardSignAlgorithm{version: SignVersionEcdsaP256, signHeaders: true, markEmptyBody: true, signAnyBody: true, tagBody: true}})
+}
+
+// SignWithPrivateKey 与 [SignWith] 相同，但使用私钥签名。
+// auth 的签名算法版本需是 [AsymmetricSignAlgorithm] ，如 [SignVersionEd25519] 、 [SignVersionEcdsaP256] 。
+func SignWithPrivateKey(r *http.Request, rewindBody bool, key crypto.Signer, auth Authorization, opt ...SignOption) SignResult {
+	return sign(r, rewindBody, auth, signOptionOf(opt), func(alg SignAlgorithm, data []byte) (string, error) {
+		asymAlg, ok := alg.(AsymmetricSignAlgorithm)
+		if !ok {
+			return "", fmt.Errorf("signature version %d does not support private keys", signVersion(auth))
+		}
+		return asymAlg.SignWithKey(key, data, auth)
+	})
+}
+
+// AppendSignWithPrivateKey 与 [AppendSignWith] 相同，但使用私钥签名，参数要求见 [SignWithPrivateKey] 。
+func AppendSignWithPrivateKey(r *http.Request, key crypto.Signer, auth Authorization, opt ...SignOption) SignResult {
+	res := SignWithPrivateKey(r, true, key, auth, opt...)
+	if res.Type != SignResultType_OK {
+		return res
+	}
+
+	auth.Sign = res.Sign
+	r.Header.Set(HttpHeaderAuthorization, BuildAuthorizationHeader(auth))
+	return res
+}
+
+// PrivateKeySignVersion 返回与私钥类型对应的签名算法版本；若类型不受支持，返回 0 。
+func PrivateKeySignVersion(key crypto.Signer) int {
+	switch pub := key.Public().(type) {
+	case ed25519.PublicKey:
+		return SignVersionEd25519
+
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P256() {
+			return SignVersionEcdsaP256
+		}
+	}
+	return 0
+}
+
+// [SignVersionEd25519] 的实现。
+type ed25519SignAlgorithm struct {
+	standardSignAlgorithm
+}
+
+// Mac 实现 [SignAlgorithm] ，总是返回错误。
+func (x ed25519SignAlgorithm) Mac(secret string, data []byte, auth Authorization) (string, error) {
+	return "", errors.New("Ed25519 signature requires a private key")
+}
+
+// SignWithKey 实现 [AsymmetricSignAlgorithm] 。
+func (x ed25519SignAlgorithm) SignWithKey(key crypto.Signer, data []byte, auth Authorization) (string, error) {
+	if _, ok := key.Public().(ed25519.PublicKey); !ok {
+		return "", fmt.Errorf("Ed25519 signature requires an Ed25519 private key, got %T", key)
+	}
+
+	// Ed25519 直接对原文签名，不预先计算摘要。
+	sig, err := key.Sign(rand.Reader, data, crypto.Hash(0))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyWithKey 实现 [AsymmetricSignAlgorithm] 。
+func (x ed25519SignAlgorithm) VerifyWithKey(key crypto.PublicKey, data []byte, sign string, auth Authorization) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("Ed25519 signature requires an Ed25519 public key, got %T", key)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+
+	if !ed25519.Verify(pub, data, sig) {
+		return errors.New("Ed25519 verification failed")
+	}
+	return nil
+}
+
+// [SignVersionEcdsaP256] 的实现。
+type ecdsaP256SignAlgorithm struct {
+	standardSignAlgorithm
+}
+
+// Mac 实现 [SignAlgorithm] ，总是返回错误。
+func (x ecdsaP256SignAlgorithm) Mac(secret string, data []byte, auth Authorization) (string, error) {
+	return "", errors.New("ECDSA signature requires a private key")
+}
+
+// SignWithKey 实现 [AsymmetricSignAlgorithm] 。
+func (x ecdsaP256SignAlgorithm) SignWithKey(key crypto.Signer, data []byte, auth Authorization) (string, error) {
+	pub, ok := key.Public().(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return "", fmt.Errorf("ECDSA signature requires a P-256 private key, got %T", key)
+	}
+
+	digest := sha256.Sum256(data)
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyWithKey 实现 [AsymmetricSignAlgorithm] 。
+func (x ecdsaP256SignAlgorithm) VerifyWithKey(key crypto.PublicKey, data []byte, sign string, auth Authorization) error {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return fmt.Errorf("ECDSA signature requires a P-256 public key, got %T", key)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return errors.New("ECDSA verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package sigauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigAuthResolver_asymmetric(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	publicKeys := map[string]crypto.PublicKey{
+		"ed": edPub,
+		"ec": &ecPriv.PublicKey,
+	}
+
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		PublicKeyFinder: func(accessKey string) crypto.PublicKey {
+			return publicKeys[accessKey]
+		},
+		TimeChecker: NoTimeChecker,
+	})
+
+	newSignedRequest := func(key string, priv crypto.Signer) *http.Request {
+		r := newRequest("", "/p?a=1", _requestTypeJson, `{"x":1}`)
+		res := AppendSignWithPrivateKey(r, priv, Authorization{
+			Key:       key,
+			Timestamp: _timestamp,
+			Version:   PrivateKeySignVersion(priv),
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+		return r
+	}
+
+	t.Run("Ed25519", func(t *testing.T) {
+		auth, err := resolver.Verify(newSignedRequest("ed", edPriv))
+		require.NoError(t, err)
+		assert.Equal(t, SignVersionEd25519, auth.Version)
+	})
+
+	t.Run("EcdsaP256", func(t *testing.T) {
+		auth, err := resolver.Verify(newSignedRequest("ec", ecPriv))
+		require.NoError(t, err)
+		assert.Equal(t, SignVersionEcdsaP256, auth.Version)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		r := newSignedRequest("ed", edPriv)
+		r.URL.RawQuery = "a=2"
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("WrongKeyType", func(t *testing.T) {
+		// 使用 ECDSA 私钥，但声明为 Ed25519 版本。
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWithPrivateKey(r, ecPriv, Authorization{Key: "ec", Timestamp: _timestamp, Version: SignVersionEd25519})
+		assert.Equal(t, SignResultType_UnsupportedAlgorithm, res.Type)
+	})
+
+	t.Run("KeyBoundToOtherType", func(t *testing.T) {
+		// 公钥是 ECDSA 的，请求却声明为 Ed25519 签名。
+		r := newSignedRequest("ed", edPriv)
+		r.Header.Set(HttpHeaderAuthorization, r.Header.Get(HttpHeaderAuthorization)+", Key=ec")
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := resolver.Verify(newSignedRequest("unknown", edPriv))
+		require.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("SymmetricVersionWithPrivateKey", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWithPrivateKey(r, edPriv, Authorization{Key: "ed", Timestamp: _timestamp})
+		assert.Equal(t, SignResultType_UnsupportedAlgorithm, res.Type)
+	})
+
+	t.Run("NoPublicKeyFinder", func(t *testing.T) {
+		resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+		_, err := resolver.Verify(newSignedRequest("ed", edPriv))
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+}
@@ -0,0 +1,173 @@
+package sigauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+)
+
+/* 当前文件提供以摘要代替 body 参与签名的实现，使 body 可以流式处理，而不需要整个读入内存。 */
+
+// HttpHeaderContentSHA256 给出 body 的 SHA-256 摘要，小写 HEX 格式。
+// 当其名称出现在 Authorization 头的 SignedHeaders 中时（仅限 v2 及以上版本），
+// 待签名串中使用此摘要代替 body ，签名时不读取 body ，不再要求 Content-Type 。
+// 此时 body 在之后被读取时计算摘要，读到末尾时若摘要不匹配，读取返回 [ErrBodyDigestMismatch] 。
+// 没有读到末尾的 body 不被校验，使用 [Middleware] 时可通过 [SigAuthHandlerOption.VerifyBodyDigestFirst] 预先校验。
+const HttpHeaderContentSHA256 = "X-Content-SHA256"
+
+// SetContentSHA256 计算请求 body 的 SHA-256 摘要，并将其设置到 X-Content-SHA256 头。
+// 若 [http.Request.GetBody] 不为 nil ，则从其获取 body 的副本计算摘要，原 body 不受影响；
+// 否则 body 会被读入内存，并替换为可重读的 [bytes.Buffer] 。
+// 对于大文件，应预先计算好摘要直接设置此头，以避免额外的读取。
+func SetContentSHA256(r *http.Request) error {
+	h := sha256.New()
+	switch {
+	case r.Body == nil || r.Body == http.NoBody:
+		// 空 body 。
+
+	case r.GetBody != nil:
+		body, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		if _, err := io.Copy(h, body); err != nil {
+			return err
+		}
+
+	default:
+		data, err := repeatableReadBody(r)
+		if err != nil {
+			return err
+		}
+		h.Write(data)
+	}
+
+	r.Header.Set(HttpHeaderContentSHA256, hex.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+// 判断 s 是否为小写 HEX 格式的 SHA-256 摘要。
+func isSha256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// 空内容的 SHA-256 摘要。
+const _sha256OfEmpty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// 将请求的 body 替换为读取时校验摘要的 body 。
+// 客户端请求没有 body 且摘要与之一致时，不做替换，以免请求被当作长度未知的 body 发送。
+func verifyBodyDigest(r *http.Request, digest string) {
+	if (r.Body == nil || r.Body == http.NoBody) && digest == _sha256OfEmpty {
+		return
+	}
+	r.Body = newDigestVerifyingBody(r.Body, digest)
+}
+
+// 若请求的 body 在读取时校验摘要，则立即读取整个 body 并校验，之后 body 被替换为可重读的 [bytes.Buffer] 。
+// 摘要不匹配时返回包装了 [ErrBodyDigestMismatch] 的 [ErrSignatureMismatch] ；读取失败时返回 [*SignError] 。
+func verifyBodyDigestNow(r *http.Request) error {
+	if _, ok := r.Body.(*digestVerifyingBody); !ok {
+		return nil
+	}
+
+	_, err := repeatableReadBody(r)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrBodyDigestMismatch):
+		return fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+	case errors.Is(err, ErrBodyTooLarge):
+		return &SignError{Type: SignResultType_BodyTooLarge, Cause: err}
+	default:
+		return &SignError{Type: SignResultType_InvalidRequestBody, Cause: err}
+	}
+}
+
+// 在读取过程中计算摘要的 body ，读到末尾时校验摘要。
+type digestVerifyingBody struct {
+	body io.ReadCloser
+	hash hash.Hash
+	want string
+	err  error // 校验失败后，之后的读取总是返回此错误。
+}
+
+// 若 body 为 nil ，视为空 body 。
+func newDigestVerifyingBody(body io.ReadCloser, digest string) io.ReadCloser {
+	if body == nil {
+		body = http.NoBody
+	}
+
+	return &digestVerifyingBody{
+		body: body,
+		hash: sha256.New(),
+		want: digest,
+	}
+}
+
+func (x *digestVerifyingBody) Read(p []byte) (int, error) {
+	if x.err != nil {
+		return 0, x.err
+	}
+
+	n, err := x.body.Read(p)
+	x.hash.Write(p[:n])
+
+	if err == io.EOF && hex.EncodeToString(x.hash.Sum(nil)) != x.want {
+		x.err = ErrBodyDigestMismatch
+		return n, x.err
+	}
+	return n, err
+}
+
+func (x *digestVerifyingBody) Close() error {
+	return x.body.Close()
+}
+
+// 限制最大读取长度的 body ，超过长度时读取返回 [ErrBodyTooLarge] 。
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64 // 还可读取的字节数。
+}
+
+func newLimitedBody(body io.ReadCloser, limit int64) io.ReadCloser {
+	return &limitedBody{
+		body:      body,
+		remaining: limit,
+	}
+}
+
+func (x *limitedBody) Read(p []byte) (int, error) {
+	// 多读取一个字节，用于判断是否超过长度。
+	if int64(len(p)) > x.remaining+1 {
+		p = p[:x.remaining+1]
+	}
+
+	n, err := x.body.Read(p)
+	if int64(n) > x.remaining {
+		n = int(x.remaining)
+		x.remaining = 0
+		return n, ErrBodyTooLarge
+	}
+
+	x.remaining -= int64(n)
+	return n, err
+}
+
+func (x *limitedBody) Close() error {
+	return x.body.Close()
+}
@@ -0,0 +1,238 @@
+package sigauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const _sha256OfAbc = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+
+func TestSetContentSHA256(t *testing.T) {
+	t.Run("GetBody", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, "http://temp.org", strings.NewReader("abc"))
+		require.NoError(t, SetContentSHA256(r))
+		assert.Equal(t, _sha256OfAbc, r.Header.Get(HttpHeaderContentSHA256))
+
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "abc", string(body))
+	})
+
+	t.Run("NoGetBody", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeJson, "abc")
+		require.NoError(t, SetContentSHA256(r))
+		assert.Equal(t, _sha256OfAbc, r.Header.Get(HttpHeaderContentSHA256))
+
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "abc", string(body))
+	})
+}
+
+func Test_buildDataToSign_contentSHA256(t *testing.T) {
+	r := newRequest("", "/p", _requestTypeGet, "abc")
+	r.Method = http.MethodPut
+	r.Header.Set(HttpHeaderContentSHA256, _sha256OfAbc)
+
+	t.Run("V2", func(t *testing.T) {
+		data, typ, err := buildDataToSign(r, false, Authorization{
+			Timestamp:     12345,
+			Version:       SignVersion2,
+			SignedHeaders: []string{HttpHeaderContentSHA256},
+		}, SignOption{})
+		require.NoError(t, err)
+		assert.Equal(t, SignResultType_OK, typ)
+
+		want := "SIGAUTH-V2\n12345\nPUT\n/p\n\n" +
+			"x-content-sha256\n" +
+			"x-content-sha256:" + _sha256OfAbc + "\n" +
+			"sha256:" + _sha256OfAbc + "\nEND"
+		assert.Equal(t, want, string(data))
+	})
+
+	t.Run("V1IgnoresDigest", func(t *testing.T) {
+		_, typ, _ := buildDataToSign(r, false, Authorization{
+			Timestamp:     12345,
+			SignedHeaders: []string{HttpHeaderContentSHA256},
+		}, SignOption{})
+		assert.Equal(t, SignResultType_InvalidSignedHeaders, typ)
+	})
+
+	t.Run("InvalidDigest", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeJson, "abc")
+		r.Header.Set(HttpHeaderContentSHA256, "ABC")
+		_, typ, _ := buildDataToSign(r, false, Authorization{
+			Timestamp:     12345,
+			Version:       SignVersion2,
+			SignedHeaders: []string{HttpHeaderContentSHA256},
+		}, SignOption{})
+		assert.Equal(t, SignResultType_InvalidRequestBody, typ)
+	})
+}
+
+func TestSigAuthResolver_streamBody(t *testing.T) {
+	// handler 返回读取到的 body ，或读取时的错误。
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		MaxBodySize:  8,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		switch {
+		case errors.Is(err, ErrBodyDigestMismatch):
+			w.Write([]byte("digest mismatch"))
+		case errors.Is(err, ErrBodyTooLarge):
+			w.Write([]byte("too large"))
+		default:
+			w.Write(body)
+		}
+	})))
+	defer s.Close()
+
+	transport := &Transport{
+		AccessKey:  _key,
+		Secret:     _secret,
+		StreamBody: true,
+		Now: func() time.Time {
+			return time.Unix(_timestamp, 0)
+		},
+	}
+	client := &http.Client{Transport: transport}
+
+	do := func(r *http.Request) (int, string) {
+		res, err := client.Do(r)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		// 没有 Content-Type 也可以签名。
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader("abc"))
+		code, body := do(r)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "abc", body)
+	})
+
+	t.Run("DigestMismatch", func(t *testing.T) {
+		// 客户端发送时也会校验摘要，故签名后替换 body ，再直接发送。
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader("abc"))
+		require.NoError(t, SetContentSHA256(r))
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:           _key,
+			Timestamp:     _timestamp,
+			Version:       SignVersion2,
+			SignedHeaders: []string{HttpHeaderContentSHA256},
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		tampered, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader("abd"))
+		tampered.Header = r.Header
+		testRequest(t, tampered, "digest mismatch")
+	})
+
+	t.Run("ClientDetectsMismatch", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader("abd"))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("abc")), nil
+		}
+		_, err := client.Do(r)
+		require.ErrorIs(t, err, ErrBodyDigestMismatch)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader("0123456789"))
+		_, body := do(r)
+		assert.Equal(t, "too large", body)
+	})
+
+	t.Run("TooLargeBuffered", func(t *testing.T) {
+		transport.StreamBody = false
+		defer func() {
+			transport.StreamBody = true
+		}()
+
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader(`{"x":"0123456789"}`))
+		r.Header.Set(HttpHeaderContentType, ContentTypeJson)
+		code, _ := do(r)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	})
+}
+
+// 测试 handler 不读到 body 末尾时，预先校验摘要
+func TestMiddleware_verifyBodyDigestFirst(t *testing.T) {
+	newServer := func(first bool) *httptest.Server {
+		return httptest.NewServer(Middleware(SigAuthHandlerOption{
+			SecretFinder:          finderForTest,
+			TimeChecker:           NoTimeChecker,
+			MaxBodySize:           16,
+			VerifyBodyDigestFirst: first,
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// json.Decoder 读完 JSON 值后即停止，不会读到末尾。
+			var v map[string]int
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, v["x"])
+		})))
+	}
+
+	newTampered := func(url, body, tampered string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		require.NoError(t, SetContentSHA256(r))
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:           _key,
+			Timestamp:     _timestamp,
+			Version:       SignVersion2,
+			SignedHeaders: []string{HttpHeaderContentSHA256},
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		tr, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(tampered))
+		tr.Header = r.Header
+		return tr
+	}
+
+	do := func(r *http.Request) (int, string) {
+		res, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		s := newServer(false)
+		defer s.Close()
+
+		// 篡改的 body 没有被发现，这是 VerifyBodyDigestFirst 要解决的问题。
+		code, body := do(newTampered(s.URL, `{"x":1}`, `{"x":2}`))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "2", body)
+	})
+
+	t.Run("VerifyFirst", func(t *testing.T) {
+		s := newServer(true)
+		defer s.Close()
+
+		code, body := do(newTampered(s.URL, `{"x":1}`, `{"x":1}`))
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "1", body)
+
+		code, _ = do(newTampered(s.URL, `{"x":1}`, `{"x":2}`))
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, _ = do(newTampered(s.URL, `{"x":1}`, `{"x":1, "padding": 12345678}`))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	})
+}
@@ -0,0 +1,277 @@
+package sigauth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+/* 当前文件提供带缓存的 SecretProvider 。 */
+
+// CachingSecretProviderOption 用于初始化 [CachingSecretProvider] ，零值的字段使用其默认值。
+type CachingSecretProviderOption struct {
+	// 最多缓存的 accessKey 数量，超出时淘汰最久未使用的。若为 0 ，则为 1024 。
+	Size int
+
+	// 查找到的 secret 的缓存时长。若为 0 ，则为 5 分钟。
+	TTL time.Duration
+
+	// 没有绑定 secret 的 accessKey 的缓存时长，用于避免大量使用无效 accessKey 的请求压垮存储。
+	// 若为 0 ，则为 1 分钟；若为负数，则不缓存。
+	NegativeTTL time.Duration
+
+	// 调用被装饰的 SecretProvider 的超时时间。若为 0 ，则为 10 秒。
+	LookupTimeout time.Duration
+}
+
+// CachingSecretProvider 是带缓存的 [SecretProvider] 装饰器：
+//   - 使用有容量上限的 LRU 缓存，每项在 TTL 后过期。
+//   - 没有绑定的 accessKey 同样被缓存（ NegativeTTL ）；查找出错时不缓存。
+//   - 同一 accessKey 的并发查找只会调用一次被装饰的 SecretProvider ，其他调用等待并共享结果。
+//     共享的调用使用的 ctx 保留第一个调用的 ctx 中的值，但不随其取消，而是使用 LookupTimeout 作为超时时间；
+//     每个调用在自己的 ctx 被取消时停止等待，返回 ctx 的错误，不影响其他调用。
+//
+// [SecretFinderFunc] 实现了 [SecretProvider] ，也可被装饰：
+//
+//	provider := sigauth.NewCachingSecretProvider(sigauth.SecretFinderFunc(finder), sigauth.CachingSecretProviderOption{})
+//
+// 并发安全。
+type CachingSecretProvider struct {
+	next        SecretProvider
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	timeout     time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // 值为 *cacheEntry 。
+	lru     *list.List               // 最近使用的在前。
+	flights map[string]*flight       // 进行中的查找，用于判断查找期间是否调用了 Invalidate 。
+	group   singleflight.Group
+}
+
+// 一个 accessKey 进行中的查找。 Invalidate 之后可能有多个同时进行的查找，它们共享此对象。
+type flight struct {
+	epoch int // 每次 Invalidate 时递增。
+	refs  int // 进行中的查找的数量，为 0 时移除。
+}
+
+type cacheEntry struct {
+	key     string
+	secret  Secret
+	unknown bool // 是否为没有绑定的 accessKey 。
+	expires time.Time
+}
+
+// NewCachingSecretProvider 创建装饰 next 的 [CachingSecretProvider] 。
+func NewCachingSecretProvider(next SecretProvider, op CachingSecretProviderOption) *CachingSecretProvider {
+	if next == nil {
+		panic("next must be provided")
+	}
+
+	size := op.Size
+	if size <= 0 {
+		size = 1024
+	}
+
+	ttl := op.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	negativeTTL := op.NegativeTTL
+	if negativeTTL == 0 {
+		negativeTTL = time.Minute
+	}
+
+	timeout := op.LookupTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &CachingSecretProvider{
+		next:        next,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		timeout:     timeout,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		flights:     make(map[string]*flight),
+	}
+}
+
+// Secret 实现 [SecretProvider] 。
+func (x *CachingSecretProvider) Secret(ctx context.Context, accessKey string) (Secret, error) {
+	if entry, ok := x.get(accessKey); ok {
+		if entry.unknown {
+			return Secret{}, ErrUnknownKey
+		}
+		return entry.secret, nil
+	}
+
+	// 共享的调用在单独的 goroutine 中执行，其 panic 在等待的调用中重新抛出，而不是使进程崩溃。
+	lookupCtx := detachedContext{ctx}
+	ch := x.group.DoChan(accessKey, func() (v interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = &lookupPanic{p}
+			}
+		}()
+		return x.lookup(lookupCtx, accessKey)
+	})
+
+	select {
+	case res := <-ch:
+		var p *lookupPanic
+		if errors.As(res.Err, &p) {
+			panic(p.value)
+		}
+		if res.Err != nil {
+			return Secret{}, res.Err
+		}
+		return res.Val.(Secret), nil
+
+	case <-ctx.Done():
+		return Secret{}, ctx.Err()
+	}
+}
+
+// 调用被装饰的 SecretProvider ，并缓存结果。
+func (x *CachingSecretProvider) lookup(ctx context.Context, accessKey string) (Secret, error) {
+	ctx, cancel := context.WithTimeout(ctx, x.timeout)
+	defer cancel()
+
+	// 查找期间若调用了 Invalidate ，得到的可能是旧值，不写入缓存。
+	var entry *cacheEntry
+	epoch := x.beginFlight(accessKey)
+	defer func() {
+		x.endFlight(accessKey, epoch, entry)
+	}()
+
+	secret, err := x.next.Secret(ctx, accessKey)
+	switch {
+	case errors.Is(err, ErrUnknownKey) || err == nil && secret.empty():
+		if x.negativeTTL > 0 {
+			entry = &cacheEntry{key: accessKey, unknown: true, expires: x.now().Add(x.negativeTTL)}
+		}
+		return Secret{}, ErrUnknownKey
+
+	case err != nil:
+		return Secret{}, err
+
+	default:
+		entry = &cacheEntry{key: accessKey, secret: secret, expires: x.now().Add(x.ttl)}
+		return secret, nil
+	}
+}
+
+// 共享的查找中发生的 panic 。
+type lookupPanic struct {
+	value interface{}
+}
+
+func (e *lookupPanic) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// 保留 parent 中的值，但不随其取消的 [context.Context] 。
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (x detachedContext) Value(key interface{}) interface{} { return x.parent.Value(key) }
+
+// Invalidate 移除 accessKey 的缓存，如在 secret 被修改或禁用之后，使下次查找直接调用被装饰的 SecretProvider 。
+func (x *CachingSecretProvider) Invalidate(accessKey string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if el, ok := x.entries[accessKey]; ok {
+		x.lru.Remove(el)
+		delete(x.entries, accessKey)
+	}
+
+	// 进行中的查找可能得到旧值，使其结果不被缓存，且之后的查找不再共享其结果。
+	if f, ok := x.flights[accessKey]; ok {
+		f.epoch++
+	}
+	x.group.Forget(accessKey)
+}
+
+// 登记 accessKey 的一次查找，返回当前的 epoch 。
+func (x *CachingSecretProvider) beginFlight(accessKey string) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	f, ok := x.flights[accessKey]
+	if !ok {
+		f = &flight{}
+		x.flights[accessKey] = f
+	}
+	f.refs++
+	return f.epoch
+}
+
+// 结束 accessKey 的一次查找，若期间没有调用 Invalidate ，且 entry 非 nil ，则写入缓存。
+func (x *CachingSecretProvider) endFlight(accessKey string, epoch int, entry *cacheEntry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	f := x.flights[accessKey]
+	if entry != nil && f.epoch == epoch {
+		x.putLocked(entry)
+	}
+
+	f.refs--
+	if f.refs == 0 {
+		delete(x.flights, accessKey)
+	}
+}
+
+// 返回未过期的缓存项，并将其移到最前。
+func (x *CachingSecretProvider) get(accessKey string) (*cacheEntry, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	el, ok := x.entries[accessKey]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if x.now().After(entry.expires) {
+		x.lru.Remove(el)
+		delete(x.entries, accessKey)
+		return nil, false
+	}
+
+	x.lru.MoveToFront(el)
+	return entry, true
+}
+
+// 写入缓存项，超出容量时淘汰最久未使用的。调用方需持有 mu 。
+func (x *CachingSecretProvider) putLocked(entry *cacheEntry) {
+	if el, ok := x.entries[entry.key]; ok {
+		el.Value = entry
+		x.lru.MoveToFront(el)
+		return
+	}
+
+	x.entries[entry.key] = x.lru.PushFront(entry)
+	for x.lru.Len() > x.size {
+		oldest := x.lru.Back()
+		x.lru.Remove(oldest)
+		delete(x.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
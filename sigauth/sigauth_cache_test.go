@@ -0,0 +1,292 @@
+package sigauth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 统计被装饰的 SecretProvider 的调用次数。
+type countingProvider struct {
+	calls   int32
+	release chan struct{} // 若非 nil ，查找时等待其关闭。
+}
+
+func (x *countingProvider) Secret(ctx context.Context, accessKey string) (Secret, error) {
+	atomic.AddInt32(&x.calls, 1)
+	if x.release != nil {
+		<-x.release
+	}
+	return providerForTest(ctx, accessKey)
+}
+
+func (x *countingProvider) count() int {
+	return int(atomic.LoadInt32(&x.calls))
+}
+
+func newCachingProviderForTest(next SecretProvider, op CachingSecretProviderOption) (*CachingSecretProvider, *time.Time) {
+	now := time.Unix(_timestamp, 0)
+	p := NewCachingSecretProvider(next, op)
+	p.now = func() time.Time { return now }
+	return p, &now
+}
+
+func TestCachingSecretProvider_ttl(t *testing.T) {
+	next := &countingProvider{}
+	p, now := newCachingProviderForTest(next, CachingSecretProviderOption{TTL: time.Minute})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		s, err := p.Secret(ctx, _key)
+		require.NoError(t, err)
+		assert.Equal(t, _secret, s.Value)
+	}
+	assert.Equal(t, 1, next.count())
+
+	*now = now.Add(time.Minute + time.Second)
+	_, err := p.Secret(ctx, _key)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.count())
+}
+
+func TestCachingSecretProvider_negative(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Cached", func(t *testing.T) {
+		next := &countingProvider{}
+		p, now := newCachingProviderForTest(next, CachingSecretProviderOption{NegativeTTL: time.Second})
+
+		for i := 0; i < 3; i++ {
+			_, err := p.Secret(ctx, "unknown")
+			require.ErrorIs(t, err, ErrUnknownKey)
+		}
+		assert.Equal(t, 1, next.count())
+
+		*now = now.Add(2 * time.Second)
+		_, err := p.Secret(ctx, "unknown")
+		require.ErrorIs(t, err, ErrUnknownKey)
+		assert.Equal(t, 2, next.count())
+	})
+
+	t.Run("EmptySecret", func(t *testing.T) {
+		var calls int
+		p, _ := newCachingProviderForTest(SecretProviderFunc(func(context.Context, string) (Secret, error) {
+			calls++
+			return Secret{}, nil
+		}), CachingSecretProviderOption{})
+
+		for i := 0; i < 2; i++ {
+			_, err := p.Secret(ctx, "unknown")
+			require.ErrorIs(t, err, ErrUnknownKey)
+		}
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		next := &countingProvider{}
+		p, _ := newCachingProviderForTest(next, CachingSecretProviderOption{NegativeTTL: -1})
+
+		for i := 0; i < 2; i++ {
+			_, err := p.Secret(ctx, "unknown")
+			require.ErrorIs(t, err, ErrUnknownKey)
+		}
+		assert.Equal(t, 2, next.count())
+	})
+
+	t.Run("ErrorNotCached", func(t *testing.T) {
+		next := &countingProvider{}
+		p, _ := newCachingProviderForTest(next, CachingSecretProviderOption{})
+
+		for i := 0; i < 2; i++ {
+			_, err := p.Secret(ctx, "down")
+			require.ErrorIs(t, err, errStoreDown)
+		}
+		assert.Equal(t, 2, next.count())
+	})
+}
+
+func TestCachingSecretProvider_lru(t *testing.T) {
+	next := &countingProvider{}
+	p, _ := newCachingProviderForTest(next, CachingSecretProviderOption{Size: 2})
+	ctx := context.Background()
+
+	get := func(key string) {
+		_, _ = p.Secret(ctx, key)
+	}
+
+	get(_key)
+	get("disabled")
+	get(_key)    // _key 成为最近使用的。
+	get("other") // 淘汰 disabled 。
+	assert.Equal(t, 3, next.count())
+
+	get(_key)
+	assert.Equal(t, 3, next.count())
+
+	get("disabled")
+	assert.Equal(t, 4, next.count())
+	assert.Equal(t, 2, p.lru.Len())
+}
+
+func TestCachingSecretProvider_Invalidate(t *testing.T) {
+	next := &countingProvider{}
+	p, _ := newCachingProviderForTest(next, CachingSecretProviderOption{})
+	ctx := context.Background()
+
+	_, _ = p.Secret(ctx, _key)
+	_, _ = p.Secret(ctx, _key)
+	assert.Equal(t, 1, next.count())
+
+	p.Invalidate(_key)
+	p.Invalidate("none")
+	_, _ = p.Secret(ctx, _key)
+	assert.Equal(t, 2, next.count())
+}
+
+// 测试查找期间调用 Invalidate 时，查找得到的旧值不被缓存
+func TestCachingSecretProvider_invalidateDuringLookup(t *testing.T) {
+	var mu sync.Mutex
+	value := "old"
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	next := SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+		mu.Lock()
+		v := value
+		mu.Unlock()
+
+		select {
+		case started <- struct{}{}:
+			<-release
+		default:
+		}
+		return Secret{Value: v}, nil
+	})
+	p := NewCachingSecretProvider(next, CachingSecretProviderOption{})
+
+	done := make(chan Secret)
+	go func() {
+		s, _ := p.Secret(context.Background(), _key)
+		done <- s
+	}()
+
+	// 查找已读到旧值，此时 secret 被修改，随后调用 Invalidate 。
+	<-started
+	mu.Lock()
+	value = "new"
+	mu.Unlock()
+	p.Invalidate(_key)
+	close(release)
+	assert.Equal(t, "old", (<-done).Value)
+
+	s, err := p.Secret(context.Background(), _key)
+	require.NoError(t, err)
+	assert.Equal(t, "new", s.Value)
+
+	// 没有进行中的查找时，不保留其记录。
+	p.mu.Lock()
+	assert.Empty(t, p.flights)
+	p.mu.Unlock()
+}
+
+func TestCachingSecretProvider_singleflight(t *testing.T) {
+	next := &countingProvider{release: make(chan struct{})}
+	p := NewCachingSecretProvider(next, CachingSecretProviderOption{})
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]Secret, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = p.Secret(context.Background(), _key)
+		}(i)
+	}
+
+	// 等待第一个查找开始后，再让其返回。
+	require.Eventually(t, func() bool { return next.count() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, next.count())
+	for _, s := range results {
+		assert.Equal(t, _secret, s.Value)
+	}
+}
+
+// 测试取消第一个调用的 ctx 不影响等待同一查找的其他调用
+func TestCachingSecretProvider_cancel(t *testing.T) {
+	next := &countingProvider{release: make(chan struct{})}
+	p := NewCachingSecretProvider(SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+		s, err := next.Secret(ctx, accessKey)
+		if ctx.Err() != nil {
+			return Secret{}, ctx.Err()
+		}
+		return s, err
+	}), CachingSecretProviderOption{})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), testCtxKey{}, true))
+	first := make(chan error)
+	go func() {
+		_, err := p.Secret(ctx, "ctx")
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return next.count() == 1 }, time.Second, time.Millisecond)
+
+	second := make(chan Secret)
+	go func() {
+		s, _ := p.Secret(context.Background(), "ctx")
+		second <- s
+	}()
+
+	// 第一个调用立即返回，共享的查找继续进行，且能取到第一个调用的 ctx 中的值。
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	close(next.release)
+	assert.Equal(t, _secret, (<-second).Value)
+	assert.Equal(t, 1, next.count())
+}
+
+func TestCachingSecretProvider_timeout(t *testing.T) {
+	next := SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+		<-ctx.Done()
+		return Secret{}, ctx.Err()
+	})
+	p := NewCachingSecretProvider(next, CachingSecretProviderOption{LookupTimeout: 10 * time.Millisecond})
+
+	_, err := p.Secret(context.Background(), _key)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCachingSecretProvider_panic(t *testing.T) {
+	p := NewCachingSecretProvider(SecretFinderFunc(func(accessKey string) string {
+		panic("finder failed")
+	}), CachingSecretProviderOption{})
+
+	assert.PanicsWithValue(t, "finder failed", func() {
+		_, _ = p.Secret(context.Background(), _key)
+	})
+}
+
+func TestCachingSecretProvider_resolver(t *testing.T) {
+	next := &countingProvider{}
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretProvider: NewCachingSecretProvider(next, CachingSecretProviderOption{}),
+		TimeChecker:    NoTimeChecker,
+	})
+
+	for i := 0; i < 3; i++ {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		_, err := resolver.Verify(r)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, next.count())
+}
@@ -0,0 +1,36 @@
+package sigauth
+
+import (
+	"log"
+	"net/http"
+)
+
+// VerifyDiagnostic 记录验签失败时的诊断信息，用于服务端排查问题。
+// 其中 ExpectedSign 等同于一个有效的签名，不能返回给客户端。
+type VerifyDiagnostic struct {
+	Err           error          // 验签返回的错误，与返回给调用方的一致。
+	Authorization *Authorization // 解析得到的 Authorization ，解析失败时为 nil 。
+	ExpectedSign  string         // 服务端计算得到的签名，未计算到这一步时为空。
+	DataToSign    string         // 服务端构建的待签名串，未计算到这一步时为空。
+}
+
+// DebugHookFunc 在验签失败时被调用，接收失败请求的 [VerifyDiagnostic] 。
+// 方法在验签过程中同步执行，不应阻塞。
+type DebugHookFunc func(r *http.Request, d VerifyDiagnostic)
+
+// LogDebugHook 返回一个 [DebugHookFunc] ，将诊断信息输出到给定的 logger 。
+// 若 logger 为 nil ，则使用 [log.Default] 。
+func LogDebugHook(logger *log.Logger) DebugHookFunc {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(r *http.Request, d VerifyDiagnostic) {
+		key := ""
+		if d.Authorization != nil {
+			key = d.Authorization.Key
+		}
+		logger.Printf("sigauth: %s %s key=%q err=%q expected=%q data=%q",
+			r.Method, r.URL.RequestURI(), key, d.Err, d.ExpectedSign, d.DataToSign)
+	}
+}
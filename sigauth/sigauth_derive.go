@@ -0,0 +1,206 @@
+package sigauth
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* 当前文件提供派生签名密钥的签名算法，见 [SignVersionDerived] 。 */
+
+// KeyDerivingSignAlgorithm 是不直接使用 secret ，而是使用由 secret 派生的签名密钥的 [SignAlgorithm] 。
+// 其 Mac 方法等同于先调用 DeriveKey ，再调用 MacWithKey 。验签方可以缓存派生的密钥。
+type KeyDerivingSignAlgorithm interface {
+	SignAlgorithm
+
+	// DeriveKey 由 secret 派生签名密钥。对于相同的 secret ，结果只取决于 auth 的 Timestamp 所在的日期（ UTC ）、
+	// Scope 和 Algorithm 。
+	DeriveKey(secret string, auth Authorization) ([]byte, error)
+
+	// MacWithKey 使用派生的签名密钥计算待签名串的签名。
+	MacWithKey(key []byte, data []byte, auth Authorization) (string, error)
+}
+
+func init() {
+	// 待签名串在 v5 的基础上，增加 SCOPE 部分。
+	RegisterSignAlgorithm(SignVersionDerived, derivedSignAlgorithm{standardSignAlgorithm{version: SignVersionDerived, signHeaders: true, markEmptyBody: true, signAnyBody: true, tagBody: true, canonicalQuery: true, signScope: true}})
+}
+
+// DeriveSigningKey 由 secret 派生签名密钥，用于 [SignVersionDerived] 。派生的密钥只能用于 timestamp 所在的日期（ UTC ）
+// 和给定的 scope 。服务端可将派生的密钥下发给客户端，客户端通过 [SignWithDerivedKey] 签名，而不需要持有 secret 。
+// algorithm 为消息认证码算法，为空时使用 [DefaultMacAlgorithm] 。
+func DeriveSigningKey(secret string, timestamp int64, scope, algorithm string) ([]byte, error) {
+	return derivedSignAlgorithm{}.DeriveKey(secret, Authorization{Timestamp: timestamp, Scope: scope, Algorithm: algorithm})
+}
+
+// SignWithDerivedKey 与 [SignWith] 相同，但使用 [DeriveSigningKey] 派生的签名密钥 key 签名。
+// auth 的签名算法版本需是 [KeyDerivingSignAlgorithm] ，如 [SignVersionDerived] ；
+// 其 Timestamp 需在派生密钥时的日期之内， Scope 、 Algorithm 需与派生密钥时的一致，否则验签不通过。
+func SignWithDerivedKey(r *http.Request, rewindBody bool, key []byte, auth Authorization, opt ...SignOption) SignResult {
+	return sign(r, rewindBody, auth, signOptionOf(opt), func(alg SignAlgorithm, data []byte) (string, error) {
+		derivingAlg, ok := alg.(KeyDerivingSignAlgorithm)
+		if !ok {
+			return "", fmt.Errorf("signature version %d does not support derived keys", signVersion(auth))
+		}
+		return derivingAlg.MacWithKey(key, data, auth)
+	})
+}
+
+// AppendSignWithDerivedKey 与 [AppendSignWith] 相同，但使用派生的签名密钥签名，参数要求见 [SignWithDerivedKey] 。
+func AppendSignWithDerivedKey(r *http.Request, key []byte, auth Authorization, opt ...SignOption) SignResult {
+	res := SignWithDerivedKey(r, true, key, auth, opt...)
+	if res.Type != SignResultType_OK {
+		return res
+	}
+
+	auth.Sign = res.Sign
+	r.Header.Set(HttpHeaderAuthorization, BuildAuthorizationHeader(auth))
+	return res
+}
+
+// [SignVersionDerived] 的实现。
+type derivedSignAlgorithm struct {
+	standardSignAlgorithm
+}
+
+// 签名密钥派生链的最后一环使用的固定值。
+const _derivedKeyTerminator = "sigauth_request"
+
+// BuildDataToSign 实现 [SignAlgorithm] 。待签名串在 v5 的基础上，在 VERSION 部分之后增加 SCOPE 部分，
+// 格式为“日期/Scope”，日期为 Timestamp 所在的 UTC 日期，如“20220831/cn-north/storage”。
+// Scope 不能为空，只能包含字母、数字和“-”、“_”、“.”，可以用“/”分为多段，每段不能为空。
+func (x derivedSignAlgorithm) BuildDataToSign(r *http.Request, rewindBody bool, auth Authorization, opt SignOption) ([]byte, SignResultType, error) {
+	if err := validateScope(auth.Scope); err != nil {
+		return nil, SignResultType_InvalidScope, err
+	}
+	return x.standardSignAlgorithm.BuildDataToSign(r, rewindBody, auth, opt)
+}
+
+// Mac 实现 [SignAlgorithm] 。
+func (x derivedSignAlgorithm) Mac(secret string, data []byte, auth Authorization) (string, error) {
+	key, err := x.DeriveKey(secret, auth)
+	if err != nil {
+		return "", err
+	}
+	return x.MacWithKey(key, data, auth)
+}
+
+// DeriveKey 实现 [KeyDerivingSignAlgorithm] 。使用 [Authorization.Algorithm] 的哈希函数，以 HMAC 链派生签名密钥：
+//
+//	kDate    = HMAC("SIGAUTH" + secret, 日期)
+//	kScope   = HMAC(kDate, Scope 的第 1 段) ，之后每一段以上一步的结果为密钥
+//	kSigning = HMAC(kScope, "sigauth_request")
+//
+// 日期为 Timestamp 所在的 UTC 日期，格式为 yyyyMMdd 。
+func (x derivedSignAlgorithm) DeriveKey(secret string, auth Authorization) ([]byte, error) {
+	mac, ok := LookupMacAlgorithm(auth.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %s", auth.Algorithm)
+	}
+
+	if err := validateScope(auth.Scope); err != nil {
+		return nil, err
+	}
+
+	sum := func(key []byte, data string) []byte {
+		h := hmac.New(mac.Hash, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+
+	key := sum([]byte("SIGAUTH"+secret), derivationDate(auth.Timestamp))
+	for _, segment := range strings.Split(auth.Scope, "/") {
+		key = sum(key, segment)
+	}
+	return sum(key, _derivedKeyTerminator), nil
+}
+
+// MacWithKey 实现 [KeyDerivingSignAlgorithm] ，使用 [Authorization.Algorithm] 指定的消息认证码算法。
+func (x derivedSignAlgorithm) MacWithKey(key []byte, data []byte, auth Authorization) (string, error) {
+	mac, ok := LookupMacAlgorithm(auth.Algorithm)
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm: %s", auth.Algorithm)
+	}
+	return mac.Mac(key, data), nil
+}
+
+// 返回时间戳所在的 UTC 日期，格式为 yyyyMMdd 。
+func derivationDate(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format("20060102")
+}
+
+// 返回时间戳所在的 UTC 日期的结束时间，即次日 0 点的 Unix 时间戳。
+func derivationDayEnd(timestamp int64) int64 {
+	t := time.Unix(timestamp, 0).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC).Unix()
+}
+
+func validateScope(scope string) error {
+	if scope == "" {
+		return errors.New("missing scope")
+	}
+
+	for _, segment := range strings.Split(scope, "/") {
+		if segment == "" {
+			return fmt.Errorf("invalid scope %q: empty segment", scope)
+		}
+
+		for i := 0; i < len(segment); i++ {
+			c := segment[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.') {
+				return fmt.Errorf("invalid scope %q: invalid character %q", scope, c)
+			}
+		}
+	}
+	return nil
+}
+
+// 派生密钥缓存的最大容量。
+const _derivedKeyCacheSize = 4096
+
+// 验签方缓存的派生签名密钥，避免每个请求都重新派生。同一 secret 、日期、 Scope 的密钥只需派生一次。
+// 达到容量上限时整个清空，旧日期的密钥随之被淘汰。
+type derivedKeyCache struct {
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func newDerivedKeyCache() *derivedKeyCache {
+	return &derivedKeyCache{keys: make(map[string][]byte)}
+}
+
+// 返回 alg 为 auth 派生的签名密钥，优先使用缓存。
+func (x *derivedKeyCache) deriveKey(alg KeyDerivingSignAlgorithm, secret string, auth Authorization) ([]byte, error) {
+	cacheKey := strings.Join([]string{
+		strconv.Itoa(signVersion(auth)),
+		auth.Algorithm,
+		derivationDate(auth.Timestamp),
+		auth.Scope,
+		secret,
+	}, "\x00")
+
+	x.mu.Lock()
+	key, ok := x.keys[cacheKey]
+	x.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := alg.DeriveKey(secret, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.keys) >= _derivedKeyCacheSize {
+		x.keys = make(map[string][]byte)
+	}
+	x.keys[cacheKey] = key
+	return key, nil
+}
@@ -0,0 +1,258 @@
+package sigauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveSigningKey(t *testing.T) {
+	sum := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+
+	// _timestamp 为 2022-08-31 08:24:11 UTC 。
+	want := sum(sum(sum(sum([]byte("SIGAUTH"+_secret), "20220831"), "cn-north"), "storage"), "sigauth_request")
+
+	key, err := DeriveSigningKey(_secret, _timestamp, "cn-north/storage", "")
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(want), hex.EncodeToString(key))
+
+	// 同一天内的时间戳派生相同的密钥。
+	endOfDay := time.Date(2022, 8, 31, 23, 59, 59, 0, time.UTC).Unix()
+	key2, err := DeriveSigningKey(_secret, endOfDay, "cn-north/storage", "")
+	require.NoError(t, err)
+	assert.Equal(t, key, key2)
+
+	for _, c := range []struct {
+		name      string
+		timestamp int64
+		scope     string
+	}{
+		{"NextDay", endOfDay + 1, "cn-north/storage"},
+		{"OtherScope", _timestamp, "cn-north/compute"},
+		{"Joined", _timestamp, "cn-northstorage"},
+	} {
+		other, err := DeriveSigningKey(_secret, c.timestamp, c.scope, "")
+		require.NoError(t, err)
+		assert.NotEqual(t, key, other, c.name)
+	}
+
+	key512, err := DeriveSigningKey(_secret, _timestamp, "cn-north/storage", MacHmacSha512)
+	require.NoError(t, err)
+	assert.Len(t, key512, 64)
+
+	_, err = DeriveSigningKey(_secret, _timestamp, "cn-north/storage", "NONE")
+	assert.Error(t, err)
+
+	for _, scope := range []string{"", "/", "a//b", "a/", "a b", "a,b", "租户"} {
+		_, err := DeriveSigningKey(_secret, _timestamp, scope, "")
+		assert.Error(t, err, scope)
+	}
+}
+
+func TestSignWith_derived(t *testing.T) {
+	auth := Authorization{
+		Key:       _key,
+		Timestamp: _timestamp,
+		Version:   SignVersionDerived,
+		Scope:     "tenant-a",
+	}
+
+	t.Run("DataToSign", func(t *testing.T) {
+		r := newRequest("", "/p?b=2&a=1", _requestTypeGet, "")
+		data, typ, err := buildDataToSign(r, false, auth, SignOption{})
+		require.NoError(t, err)
+		require.Equal(t, SignResultType_OK, typ)
+		assert.Equal(t, "SIGAUTH-V6\n20220831/tenant-a\n1661934251\nGET\n/p\na=1&b=2\n\nEND", string(data))
+	})
+
+	t.Run("DerivedKey", func(t *testing.T) {
+		r := newRequest("", "/p?a=1", _requestTypeJson, `{"x":1}`)
+		res := SignWith(r, true, _secret, auth)
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		key, err := DeriveSigningKey(_secret, _timestamp, "tenant-a", "")
+		require.NoError(t, err)
+		res2 := SignWithDerivedKey(r, true, key, auth)
+		require.Equal(t, SignResultType_OK, res2.Type)
+		assert.Equal(t, res.Sign, res2.Sign)
+
+		// secret 本身不能直接用作派生的密钥。
+		res3 := SignWithDerivedKey(r, true, []byte(_secret), auth)
+		assert.NotEqual(t, res.Sign, res3.Sign)
+	})
+
+	t.Run("MissingScope", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := SignWith(r, false, _secret, Authorization{Timestamp: _timestamp, Version: SignVersionDerived})
+		assert.Equal(t, SignResultType_InvalidScope, res.Type)
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := SignWithDerivedKey(r, false, []byte("key"), Authorization{Timestamp: _timestamp, Version: SignVersion5})
+		assert.Equal(t, SignResultType_UnsupportedAlgorithm, res.Type)
+	})
+}
+
+func TestSigAuthResolver_derived(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+	})
+
+	newExpiringRequest := func(scope string, expires int64) *http.Request {
+		key, err := DeriveSigningKey(_secret, _timestamp, scope, "")
+		require.NoError(t, err)
+
+		r := newRequest("", "/p?a=1", _requestTypeGet, "")
+		res := AppendSignWithDerivedKey(r, key, Authorization{
+			Key:       _key,
+			Timestamp: _timestamp,
+			Expires:   expires,
+			Version:   SignVersionDerived,
+			Scope:     scope,
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+		return r
+	}
+
+	newSignedRequest := func(scope string) *http.Request {
+		return newExpiringRequest(scope, 0)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			auth, err := resolver.Verify(newSignedRequest("cn-north/storage"))
+			require.NoError(t, err)
+			assert.Equal(t, "cn-north/storage", auth.Scope)
+			assert.Equal(t, SignVersionDerived, auth.Version)
+		}
+		assert.Len(t, resolver.derivedKeys.keys, 1)
+	})
+
+	t.Run("TamperedScope", func(t *testing.T) {
+		r := newSignedRequest("cn-north/storage")
+		r.Header.Set(HttpHeaderAuthorization, strings.Replace(r.Header.Get(HttpHeaderAuthorization), "storage", "compute", 1))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("TamperedTimestamp", func(t *testing.T) {
+		// 派生的密钥只能用于派生时的日期。
+		r := newSignedRequest("cn-north/storage")
+		r.Header.Set(HttpHeaderAuthorization, strings.Replace(r.Header.Get(HttpHeaderAuthorization), "Timestamp=1661934251", "Timestamp=1662020651", 1))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("InvalidScope", func(t *testing.T) {
+		r := newSignedRequest("cn-north/storage")
+		r.Header.Set(HttpHeaderAuthorization, strings.Replace(r.Header.Get(HttpHeaderAuthorization), "Scope=cn-north/storage", "Scope=cn-north//storage", 1))
+		_, err := resolver.Verify(r)
+
+		var signErr *SignError
+		require.ErrorAs(t, err, &signErr)
+		assert.Equal(t, SignResultType_InvalidScope, signErr.Type)
+	})
+
+	t.Run("Scopes", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+			Scopes:       []string{"cn-north/storage"},
+		})
+
+		_, err := resolver.Verify(newSignedRequest("cn-north/storage"))
+		require.NoError(t, err)
+
+		// 签名正确，但派生的密钥不是给此服务的。
+		_, err = resolver.Verify(newSignedRequest("cn-north/compute"))
+		require.ErrorIs(t, err, ErrScopeNotAllowed)
+		assert.Equal(t, http.StatusForbidden, ErrorStatusCode(err))
+
+		// 签名不正确时不校验 Scope 。
+		r := newSignedRequest("cn-north/compute")
+		r.URL.RawQuery = "a=2"
+		_, err = resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("ExpiresWithinDay", func(t *testing.T) {
+		// _timestamp 为 2022-08-31 08:24:11 UTC ，派生的密钥在 2022-09-01 00:00:00 UTC 之后不可用。
+		dayEnd := int64(1661990400)
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			MaxLifetime:  DefaultMaxLifetime,
+			ExpiryTimeChecker: func(timestamp, expires int64) error {
+				return nil
+			},
+		})
+
+		_, err := resolver.Verify(newExpiringRequest("cn-north/storage", dayEnd))
+		require.NoError(t, err)
+
+		_, err = resolver.Verify(newExpiringRequest("cn-north/storage", dayEnd+1))
+		require.ErrorIs(t, err, ErrTimestampOutOfRange)
+	})
+
+	t.Run("ScopeNotSigned", func(t *testing.T) {
+		// 不签名 Scope 的版本不接受 Scope ，以免未签名的 Scope 被当作可信的。
+		r := newRequest("", "/p?a=1", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Version: SignVersion5, Scope: "cn-north/storage"})
+		assert.Equal(t, SignResultType_InvalidScope, res.Type)
+
+		r = newRequest("", "/p?a=1", _requestTypeGet, "")
+		require.Equal(t, SignResultType_OK, AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Version: SignVersion5}).Type)
+		r.Header.Set(HttpHeaderAuthorization, r.Header.Get(HttpHeaderAuthorization)+", Scope=cn-north/storage")
+		_, err := resolver.Verify(r)
+
+		var signErr *SignError
+		require.ErrorAs(t, err, &signErr)
+		assert.Equal(t, SignResultType_InvalidScope, signErr.Type)
+	})
+
+	t.Run("Transport", func(t *testing.T) {
+		s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, _ := AuthorizationFromContext(r.Context())
+			fmt.Fprintf(w, "%d:%s", auth.Version, auth.Scope)
+		})))
+		defer s.Close()
+
+		client := &http.Client{
+			Transport: &Transport{AccessKey: _key, Secret: _secret, Scope: "tenant-a"},
+		}
+		res, err := client.Get(s.URL + "/p")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, "6:tenant-a", string(body))
+	})
+}
+
+func TestBuildAuthorizationHeader_scope(t *testing.T) {
+	header := BuildAuthorizationHeader(Authorization{Key: _key, Sign: "s", Timestamp: _timestamp, Version: SignVersionDerived, Scope: "a/b"})
+	assert.Equal(t, "SIG-AUTH Key=testKey, Sign=s, Timestamp=1661934251, Version=6, Scope=a/b", header)
+
+	r := newRequest("", "/", _requestTypeGet, "")
+	r.Header.Set(HttpHeaderAuthorization, header)
+	auth, err := ParseAuthorizationHeader(r, "")
+	require.NoError(t, err)
+	assert.Equal(t, "a/b", auth.Scope)
+}
@@ -0,0 +1,227 @@
+package sigauth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/* 当前文件提供 secret 的加密存储。 */
+
+// KeyEncryptionKey 是信封加密中的主密钥（ KEK ），用于加密和解密每个 secret 各自的数据密钥（ DEK ）。
+// 主密钥可以保存在本地文件（ [LocalKeyEncryptionKey] ）中，也可以由外部的密钥管理服务实现。实现需是并发安全的。
+type KeyEncryptionKey interface {
+	// WrapKey 加密数据密钥。
+	WrapKey(ctx context.Context, dek []byte) ([]byte, error)
+
+	// UnwrapKey 解密 WrapKey 的结果。
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyEncryptionKey 是使用 AES-GCM 的本地 [KeyEncryptionKey] 。
+type LocalKeyEncryptionKey struct {
+	aead cipher.AEAD
+}
+
+// NewLocalKeyEncryptionKey 使用 AES 密钥 key 创建 [LocalKeyEncryptionKey] ， key 的长度需为 16 、 24 或 32 字节。
+func NewLocalKeyEncryptionKey(key []byte) (*LocalKeyEncryptionKey, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyEncryptionKey{aead: aead}, nil
+}
+
+// LoadLocalKeyEncryptionKey 从文件 path 读取主密钥并创建 [LocalKeyEncryptionKey] 。
+// 文件内容为 base64 编码的 32 字节的随机数，首尾的空白被忽略，可以这样生成：
+//
+//	head -c 32 /dev/urandom | base64 > master.key
+//
+// 主密钥文件需和保存加密的 secret 的文件分开存放，并限制其访问权限。
+func LoadLocalKeyEncryptionKey(path string) (*LocalKeyEncryptionKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode master key %s: %w", path, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key %s must be 32 bytes, got %d", path, len(key))
+	}
+	return NewLocalKeyEncryptionKey(key)
+}
+
+// WrapKey 实现 [KeyEncryptionKey] 。
+func (x *LocalKeyEncryptionKey) WrapKey(ctx context.Context, dek []byte) ([]byte, error) {
+	return sealGCM(x.aead, dek, nil)
+}
+
+// UnwrapKey 实现 [KeyEncryptionKey] 。
+func (x *LocalKeyEncryptionKey) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return openGCM(x.aead, wrapped, nil)
+}
+
+// 加密后的 secret 的前缀，用于识别格式。
+const _sealedSecretPrefix = "enc:v1:"
+
+// SealSecret 使用信封加密的方式加密 secret ：生成随机的数据密钥，以 AES-256-GCM 加密 secret ，
+// 再用 kek 加密数据密钥。 accessKey 作为附加数据参与认证，加密结果只能用于同一 accessKey 。
+//
+// 结果的格式为 enc:v1:<加密的数据密钥>:<加密的 secret> ，两者均为 base64url 编码，可直接写入
+// [FileKeyStore] 的文件，并通过 [NewEncryptedSecretProvider] 解密。
+func SealSecret(ctx context.Context, kek KeyEncryptionKey, accessKey, secret string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+
+	aead, err := newSecretAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := sealGCM(aead, []byte(secret), []byte(accessKey))
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := kek.WrapKey(ctx, dek)
+	if err != nil {
+		return "", fmt.Errorf("wrap data key: %w", err)
+	}
+
+	return _sealedSecretPrefix +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// OpenSecret 解密 [SealSecret] 的结果。 accessKey 需与加密时的一致。
+func OpenSecret(ctx context.Context, kek KeyEncryptionKey, accessKey, sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, _sealedSecretPrefix) {
+		return "", errors.New("secret is not encrypted")
+	}
+
+	wrappedText, ciphertextText, ok := strings.Cut(sealed[len(_sealedSecretPrefix):], ":")
+	if !ok {
+		return "", errors.New("malformed encrypted secret")
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(wrappedText)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted secret: %w", err)
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(ciphertextText)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted secret: %w", err)
+	}
+
+	dek, err := kek.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("unwrap data key: %w", err)
+	}
+
+	aead, err := newSecretAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := openGCM(aead, ciphertext, []byte(accessKey))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NewEncryptedSecretProvider 返回解密 next 所返回的 secret 的 [SecretProvider] 。
+// next 返回的 [Secret.Value] 和 [SecretVersion.Value] 需为 [SealSecret] 的结果，否则查找失败。
+//
+// secret 只以加密的形式存储，每次查找时解密。配合 [CachingSecretProvider] 使用，
+// 可以避免每次验签都调用 kek ，明文的 secret 只保存在内存中。
+// 此时不能再将 [FileKeyStore.Find] 作为 SecretFinder 使用，其返回的是加密的 secret ：
+//
+//	store, _ := sigauth.NewFileKeyStore("keys.yaml", sigauth.FileKeyStoreOption{})
+//	kek, _ := sigauth.LoadLocalKeyEncryptionKey("/etc/sigauth/master.key")
+//	provider := sigauth.NewCachingSecretProvider(
+//		sigauth.NewEncryptedSecretProvider(store, kek),
+//		sigauth.CachingSecretProviderOption{})
+func NewEncryptedSecretProvider(next SecretProvider, kek KeyEncryptionKey) SecretProvider {
+	if next == nil || kek == nil {
+		panic("next and kek must be provided")
+	}
+
+	return SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+		s, err := next.Secret(ctx, accessKey)
+		if err != nil || s.empty() {
+			return s, err
+		}
+
+		if s.Value != "" {
+			if s.Value, err = OpenSecret(ctx, kek, accessKey, s.Value); err != nil {
+				return Secret{}, fmt.Errorf("decrypt secret: %w", err)
+			}
+		}
+
+		if len(s.Versions) > 0 {
+			versions := make([]SecretVersion, len(s.Versions))
+			for i, v := range s.Versions {
+				if v.Value != "" {
+					if v.Value, err = OpenSecret(ctx, kek, accessKey, v.Value); err != nil {
+						return Secret{}, fmt.Errorf("decrypt secret generation %d: %w", v.Generation, err)
+					}
+				}
+				versions[i] = v
+			}
+			s.Versions = versions
+		}
+		return s, nil
+	})
+}
+
+func newSecretAEAD(dek []byte) (cipher.AEAD, error) {
+	if len(dek) != 32 {
+		return nil, errors.New("invalid data key")
+	}
+
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 加密 plaintext ，结果为随机的 nonce 加上密文。
+func sealGCM(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// 解密 sealGCM 的结果。
+func openGCM(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("decryption failed")
+	}
+	return plaintext, nil
+}
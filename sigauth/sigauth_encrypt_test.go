@@ -0,0 +1,180 @@
+package sigauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKEKForTest(t *testing.T) *LocalKeyEncryptionKey {
+	kek, err := NewLocalKeyEncryptionKey(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	return kek
+}
+
+func TestLoadLocalKeyEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	kek, err := LoadLocalKeyEncryptionKey(write("ok.key", key+"\n"))
+	require.NoError(t, err)
+
+	// 与直接使用同一密钥创建的 KEK 可以互相解密。
+	wrapped, err := kek.WrapKey(context.Background(), []byte("dek"))
+	require.NoError(t, err)
+	dek, err := newKEKForTest(t).UnwrapKey(context.Background(), wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("dek"), dek)
+
+	_, err = LoadLocalKeyEncryptionKey(filepath.Join(dir, "none.key"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = LoadLocalKeyEncryptionKey(write("bad.key", "!!"))
+	assert.Error(t, err)
+
+	_, err = LoadLocalKeyEncryptionKey(write("short.key", base64.StdEncoding.EncodeToString(make([]byte, 16))))
+	assert.Error(t, err)
+}
+
+func TestSealSecret(t *testing.T) {
+	ctx := context.Background()
+	kek := newKEKForTest(t)
+
+	sealed, err := SealSecret(ctx, kek, _key, _secret)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "enc:v1:"))
+	assert.NotContains(t, sealed, _secret)
+
+	// 每次加密使用不同的数据密钥和 nonce 。
+	sealed2, err := SealSecret(ctx, kek, _key, _secret)
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, sealed2)
+
+	secret, err := OpenSecret(ctx, kek, _key, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, _secret, secret)
+
+	t.Run("OtherAccessKey", func(t *testing.T) {
+		_, err := OpenSecret(ctx, kek, "other", sealed)
+		assert.Error(t, err)
+	})
+
+	t.Run("OtherKEK", func(t *testing.T) {
+		other, err := NewLocalKeyEncryptionKey(bytes.Repeat([]byte{2}, 32))
+		require.NoError(t, err)
+		_, err = OpenSecret(ctx, other, _key, sealed)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		i := strings.LastIndexByte(sealed, ':')
+		ciphertext, err := base64.RawURLEncoding.DecodeString(sealed[i+1:])
+		require.NoError(t, err)
+		ciphertext[len(ciphertext)-1] ^= 1
+		_, err = OpenSecret(ctx, kek, _key, sealed[:i+1]+base64.RawURLEncoding.EncodeToString(ciphertext))
+		assert.Error(t, err)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		for _, s := range []string{_secret, "enc:v1:", "enc:v1:!!:AA", "enc:v1:AA:!!", "enc:v1:AA:AA"} {
+			_, err := OpenSecret(ctx, kek, _key, s)
+			assert.Error(t, err, s)
+		}
+	})
+}
+
+func TestEncryptedSecretProvider(t *testing.T) {
+	ctx := context.Background()
+	kek := newKEKForTest(t)
+
+	seal := func(accessKey, secret string) string {
+		sealed, err := SealSecret(ctx, kek, accessKey, secret)
+		require.NoError(t, err)
+		return sealed
+	}
+
+	stored := map[string]Secret{
+		_key:     {Value: seal(_key, _secret), Owner: "owner"},
+		"rotate": {Versions: []SecretVersion{{Generation: 2, Value: seal("rotate", "s2")}, {Generation: 1, Value: seal("rotate", "s1")}}},
+		"plain":  {Value: _secret},
+		"moved":  {Value: seal(_key, _secret)},
+	}
+	provider := NewEncryptedSecretProvider(SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+		s, ok := stored[accessKey]
+		if !ok {
+			return Secret{}, ErrUnknownKey
+		}
+		return s, nil
+	}), kek)
+
+	s, err := provider.Secret(ctx, _key)
+	require.NoError(t, err)
+	assert.Equal(t, Secret{Value: _secret, Owner: "owner"}, s)
+
+	s, err = provider.Secret(ctx, "rotate")
+	require.NoError(t, err)
+	assert.Equal(t, []SecretVersion{{Generation: 2, Value: "s2"}, {Generation: 1, Value: "s1"}}, s.Versions)
+	assert.True(t, strings.HasPrefix(stored["rotate"].Versions[0].Value, "enc:v1:"), "stored versions should not be modified")
+
+	_, err = provider.Secret(ctx, "none")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// 未加密的，以及属于其他 accessKey 的 secret 均不可用。
+	_, err = provider.Secret(ctx, "plain")
+	assert.Error(t, err)
+	_, err = provider.Secret(ctx, "moved")
+	assert.Error(t, err)
+}
+
+func TestEncryptedSecretProvider_fileKeyStore(t *testing.T) {
+	ctx := context.Background()
+	kek := newKEKForTest(t)
+
+	sealed, err := SealSecret(ctx, kek, _key, _secret)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.env")
+	writeKeyFile(t, path, _key+"="+sealed+"\n", time.Now())
+
+	store, err := NewFileKeyStore(path, FileKeyStoreOption{PollInterval: -1})
+	require.NoError(t, err)
+	defer store.Close()
+
+	var unwraps int
+	countingKEK := &countingKeyEncryptionKey{KeyEncryptionKey: kek, unwraps: &unwraps}
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretProvider: NewCachingSecretProvider(NewEncryptedSecretProvider(store, countingKEK), CachingSecretProviderOption{}),
+		TimeChecker:    NoTimeChecker,
+	})
+
+	for i := 0; i < 3; i++ {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		_, err := resolver.Verify(r)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, unwraps)
+}
+
+type countingKeyEncryptionKey struct {
+	KeyEncryptionKey
+	unwraps *int
+}
+
+func (x *countingKeyEncryptionKey) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	*x.unwraps++
+	return x.KeyEncryptionKey.UnwrapKey(ctx, wrapped)
+}
@@ -0,0 +1,79 @@
+package sigauth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 验签失败时 [sigAuthResolver.Verify] 返回的错误，可通过 [errors.Is] 判断具体原因。
+// 返回的错误可能包装了更具体的描述信息，故不应直接用 == 比较。
+var (
+	// ErrMissingAuthorization 请求既没有 Authorization 头，也没有 ~auth 参数。
+	ErrMissingAuthorization = errors.New("missing the Authorization header")
+
+	// ErrInvalidAuthorization Authorization 的格式错误。
+	ErrInvalidAuthorization = errors.New("invalid Authorization")
+
+	// ErrUnsupportedVersion 签名算法版本不受支持。
+	ErrUnsupportedVersion = errors.New("unsupported signature version")
+
+	// ErrUnsupportedAlgorithm 消息认证码算法没有注册，或不在 [SigAuthHandlerOption.Algorithms] 之中。
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+
+	// ErrUnknownKey 给定的 Key 没有绑定 secret 。
+	ErrUnknownKey = errors.New("unknown key")
+
+	// ErrKeyDisabled 给定的 Key 绑定的 secret 已被禁用，见 [Secret.Disabled] 。
+	ErrKeyDisabled = errors.New("key disabled")
+
+	// ErrSecretLookup 通过 [SecretProvider] 查找 secret 时出错，如存储不可用。其描述可能包含内部信息，不应展示给客户端。
+	ErrSecretLookup = errors.New("failed to look up the secret")
+
+	// ErrTimestampOutOfRange 时间戳校验不通过。
+	ErrTimestampOutOfRange = errors.New("timestamp error")
+
+	// ErrSignatureMismatch 签名不匹配。
+	ErrSignatureMismatch = errors.New("signature mismatch")
+
+	// ErrBodyTooLarge 请求的 body 超过了 [SigAuthHandlerOption.MaxBodySize] 。
+	// 签名时发生则包装在 [*SignError] 中返回；使用 X-Content-SHA256 时，在读取 body 时返回。
+	ErrBodyTooLarge = errors.New("request body too large")
+
+	// ErrBodyDigestMismatch body 与 X-Content-SHA256 头给出的摘要不匹配，在读到 body 末尾时返回。
+	ErrBodyDigestMismatch = errors.New("request body digest mismatch")
+
+	// ErrMissingNonce 配置了 [NonceStore] ，但 Authorization 没有给出 Nonce 。
+	ErrMissingNonce = errors.New("missing nonce")
+
+	// ErrReplayedNonce Nonce 已被使用过，请求可能被重放。
+	ErrReplayedNonce = errors.New("nonce has been used")
+
+	// ErrScopeNotAllowed 签名正确，但 [Authorization.Scope] 不在 [SigAuthHandlerOption.Scopes] 之中。
+	ErrScopeNotAllowed = errors.New("scope not allowed")
+)
+
+// SignError 表示签名计算失败，如缺少 Content-Type 、 body 格式错误等。
+// 可通过 [errors.As] 获取，并从 Type 字段得到具体的 [SignResultType] 。
+type SignError struct {
+	Type  SignResultType // 签名结果，不会是 [SignResultType_OK] 。
+	Cause error          // 失败的具体原因，可能为 nil 。
+}
+
+func (e *SignError) Error() string {
+	if e.Cause == nil {
+		return e.Type.String()
+	}
+	return fmt.Sprintf("%s: %s", e.Type.String(), e.Cause.Error())
+}
+
+func (e *SignError) Unwrap() error {
+	return e.Cause
+}
+
+// 将 [SignResult] 转换为 error ，签名成功时返回 nil 。
+func (x SignResult) err() error {
+	if x.Type == SignResultType_OK {
+		return nil
+	}
+	return &SignError{Type: x.Type, Cause: x.Cause}
+}
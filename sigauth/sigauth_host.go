@@ -0,0 +1,147 @@
+package sigauth
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+/* 当前文件提供 SCHEME 、 HOST 部分的实现，及可信代理转发头的处理。 */
+
+const (
+	// HttpHeaderForwarded 对应 HTTP 头中的 Forwarded 字段，见 RFC 7239 。
+	HttpHeaderForwarded = "Forwarded"
+
+	// HttpHeaderXForwardedHost 对应 HTTP 头中的 X-Forwarded-Host 字段。
+	HttpHeaderXForwardedHost = "X-Forwarded-Host"
+
+	// HttpHeaderXForwardedProto 对应 HTTP 头中的 X-Forwarded-Proto 字段。
+	HttpHeaderXForwardedProto = "X-Forwarded-Proto"
+)
+
+// 返回请求的 scheme 和 host ，优先使用 opt 中由验签方给出的值。
+//   - 客户端请求： scheme 取 URL 上的 scheme ； host 取 [http.Request.Host] ，其为空时取 URL 上的 Host 。
+//   - 服务端请求： URL 上没有 scheme ，有 TLS 连接时为 https ，否则为 http ； host 取 [http.Request.Host] 。
+func requestSchemeAndHost(r *http.Request, opt SignOption) (scheme, host string) {
+	scheme, host = opt.scheme, opt.host
+
+	if scheme == "" {
+		switch {
+		case r.URL.Scheme != "":
+			scheme = r.URL.Scheme
+		case r.TLS != nil:
+			scheme = "https"
+		default:
+			scheme = "http"
+		}
+	}
+
+	if host == "" {
+		host = r.Host
+		if host == "" {
+			host = r.URL.Host
+		}
+	}
+
+	return strings.ToLower(scheme), host
+}
+
+// 返回规范化的 authority ：转为小写，并去掉与 scheme 对应的默认端口（ http 为 80 ， https 为 443 ）。
+func canonicalAuthority(scheme, host string) string {
+	host = strings.ToLower(host)
+
+	switch {
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	default:
+		return host
+	}
+}
+
+// 可信代理的地址段。
+type trustedProxies []netip.Prefix
+
+// 解析可信代理列表，每项为 IP 或 CIDR ，格式错误时 panic 。
+func parseTrustedProxies(proxies []string) trustedProxies {
+	res := make(trustedProxies, 0, len(proxies))
+	for _, p := range proxies {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				panic("invalid trusted proxy: " + p)
+			}
+			res = append(res, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			panic("invalid trusted proxy: " + p)
+		}
+		addr = addr.Unmap()
+		res = append(res, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return res
+}
+
+// 判断 remoteAddr （ [http.Request.RemoteAddr] ，形如“ip:port”）是否为可信代理。
+func (x trustedProxies) contains(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range x {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从转发头中读取原始请求的 scheme 和 host ，没有时为空。优先使用 Forwarded 头，其次是 X-Forwarded-* 头。
+// 多个代理时取最后一项，即与当前服务相邻的可信代理所给出的值，更早的项可能被客户端伪造。
+func forwardedSchemeAndHost(r *http.Request) (scheme, host string) {
+	if forwarded := lastHeaderElement(r.Header, HttpHeaderForwarded); forwarded != "" {
+		for _, pair := range strings.Split(forwarded, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "proto":
+				scheme = value
+			case "host":
+				host = value
+			}
+		}
+		return scheme, host
+	}
+
+	return lastHeaderElement(r.Header, HttpHeaderXForwardedProto), lastHeaderElement(r.Header, HttpHeaderXForwardedHost)
+}
+
+// 返回以逗号分隔的 HTTP 头的最后一项，没有时为空。
+func lastHeaderElement(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+
+	last := values[len(values)-1]
+	if idx := strings.LastIndexByte(last, ','); idx >= 0 {
+		last = last[idx+1:]
+	}
+	return strings.TrimSpace(last)
+}
@@ -0,0 +1,130 @@
+package sigauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_canonicalAuthority(t *testing.T) {
+	cases := []struct {
+		scheme, host, want string
+	}{
+		{"http", "Temp.ORG", "temp.org"},
+		{"http", "temp.org:80", "temp.org"},
+		{"https", "temp.org:80", "temp.org:80"},
+		{"https", "temp.org:443", "temp.org"},
+		{"http", "temp.org:8080", "temp.org:8080"},
+		{"https", "[::1]:443", "[::1]"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, canonicalAuthority(c.scheme, c.host), c.host)
+	}
+}
+
+func Test_buildDataToSign_host(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "https://Staging.Temp.org:443/p", nil)
+
+	data, _, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{SignHost: true, SignScheme: true})
+	require.NoError(t, err)
+	assert.Equal(t, "12345\nGET\nhttps\nstaging.temp.org\n/p\n\nEND", string(data))
+
+	data, _, _ = buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{SignHost: true})
+	assert.Equal(t, "12345\nGET\nstaging.temp.org\n/p\n\nEND", string(data))
+}
+
+func Test_trustedProxies(t *testing.T) {
+	proxies := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+
+	assert.True(t, proxies.contains("10.1.2.3:1234"))
+	assert.True(t, proxies.contains("192.0.2.1:80"))
+	assert.True(t, proxies.contains("[::ffff:192.0.2.1]:80"))
+	assert.True(t, proxies.contains("[2001:db8::1]:443"))
+	assert.False(t, proxies.contains("192.0.2.2:80"))
+	assert.False(t, proxies.contains("bad"))
+
+	assert.Panics(t, func() { parseTrustedProxies([]string{"10.0.0.0/99"}) })
+	assert.Panics(t, func() { parseTrustedProxies([]string{"localhost"}) })
+}
+
+func Test_forwardedSchemeAndHost(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Add(HttpHeaderForwarded, `for=1.1.1.1;host=evil.org;proto=http`)
+	r.Header.Add(HttpHeaderForwarded, `for=2.2.2.2, for=3.3.3.3;Host="api.temp.org";proto=https`)
+	r.Header.Set(HttpHeaderXForwardedHost, "ignored.org")
+
+	scheme, host := forwardedSchemeAndHost(r)
+	assert.Equal(t, "https", scheme)
+	assert.Equal(t, "api.temp.org", host)
+
+	r.Header.Del(HttpHeaderForwarded)
+	r.Header.Set(HttpHeaderXForwardedProto, "http, https")
+	r.Header.Set(HttpHeaderXForwardedHost, "evil.org, api.temp.org")
+	scheme, host = forwardedSchemeAndHost(r)
+	assert.Equal(t, "https", scheme)
+	assert.Equal(t, "api.temp.org", host)
+}
+
+func TestSigAuthResolver_signHost(t *testing.T) {
+	opt := SignOption{SignHost: true, SignScheme: true}
+
+	signedFor := func(rawURL string) http.Header {
+		r, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		require.Equal(t, SignResultType_OK, AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: 1}, opt).Type)
+		return r.Header
+	}
+
+	t.Run("Direct", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+			SignOption:   opt,
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "https://api.temp.org/p", nil)
+		r.Header = signedFor("https://api.temp.org/p")
+		_, err := resolver.Verify(r)
+		require.NoError(t, err)
+
+		// 为测试环境生成的签名不能用于生产环境。
+		r.Header = signedFor("https://staging.temp.org/p")
+		_, err = resolver.Verify(r)
+		assert.ErrorIs(t, err, ErrSignatureMismatch)
+
+		// 没有配置可信代理时，忽略转发头。
+		r = httptest.NewRequest(http.MethodGet, "http://internal:8080/p", nil)
+		r.Header = signedFor("https://api.temp.org/p")
+		r.Header.Set(HttpHeaderXForwardedHost, "api.temp.org")
+		r.Header.Set(HttpHeaderXForwardedProto, "https")
+		_, err = resolver.Verify(r)
+		assert.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("TrustedProxy", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder:   finderForTest,
+			TimeChecker:    NoTimeChecker,
+			TrustedProxies: []string{"10.0.0.0/8"},
+			SignOption:     opt,
+		})
+
+		newProxiedRequest := func(remoteAddr string) *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "http://internal:8080/p", nil)
+			r.RemoteAddr = remoteAddr
+			r.Header = signedFor("https://api.temp.org/p")
+			r.Header.Set(HttpHeaderForwarded, "for=198.51.100.1;host=api.temp.org;proto=https")
+			return r
+		}
+
+		_, err := resolver.Verify(newProxiedRequest("10.0.0.5:34567"))
+		require.NoError(t, err)
+
+		// 不是来自可信代理的转发头被忽略。
+		_, err = resolver.Verify(newProxiedRequest("198.51.100.1:34567"))
+		assert.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+}
@@ -0,0 +1,369 @@
+package sigauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/* 当前文件提供基于文件的 accessKey 和 secret 的存储。 */
+
+// KeyFileFormat 是 [FileKeyStore] 读取的文件格式。
+type KeyFileFormat string
+
+const (
+	// 根据文件扩展名判断： .json 为 JSON ， .yaml 和 .yml 为 YAML ，其余为 env 格式。
+	KeyFileFormatAuto KeyFileFormat = ""
+
+	// JSON 格式，顶层为 accessKey 到 secret 的映射，值可以是字符串或对象：
+	//
+	//	{
+	//	  "testkey1": "secret1",
+	//	  "testkey2": {"secret": "secret2", "owner": "team-a", "scopes": ["read"], "disabled": false}
+	//	}
+	KeyFileFormatJSON KeyFileFormat = "json"
+
+	// YAML 格式，结构同 JSON 格式：
+	//
+	//	testkey1: secret1
+	//	testkey2:
+	//	  secret: secret2
+	//	  owner: team-a
+	KeyFileFormatYAML KeyFileFormat = "yaml"
+
+	// env 格式，每行一个 KEY=SECRET ，忽略空行和 # 开头的注释行，允许 export 前缀，
+	// 值可以用单引号或双引号括起来。只支持 secret ，不支持其他元数据。
+	KeyFileFormatEnv KeyFileFormat = "env"
+)
+
+// DefaultKeyFilePollInterval 是 [FileKeyStore] 默认检查文件变化的时间间隔。
+const DefaultKeyFilePollInterval = 5 * time.Second
+
+// FileKeyStoreOption 用于初始化 [FileKeyStore] 。
+type FileKeyStoreOption struct {
+	// 文件格式，默认根据文件扩展名判断。
+	Format KeyFileFormat
+
+	// 检查文件修改时间的间隔。若为 0 ，则为 [DefaultKeyFilePollInterval] ；若为负数，则不自动重新加载，
+	// 只在调用 [FileKeyStore.Reload] 时加载。
+	PollInterval time.Duration
+
+	// 可选，自动重新加载失败时被调用，此时继续使用之前加载的内容。若为 nil ，则输出到 [log.Default] 。
+	OnReloadError func(err error)
+}
+
+// FileKeyStore 从文件读取 accessKey 和 secret ，并在文件修改后自动重新加载。
+//
+// 通过轮询文件的修改时间和大小检测变化，不依赖额外的文件监听机制。
+// 新内容完整解析成功后才原子地替换旧的内容，进行中的验签不受影响；解析失败时保留旧的内容。
+// 读取期间文件发生变化时，会在下次轮询时重新读取。为避免读到写了一半的文件，
+// 修改文件时宜先写入临时文件，再重命名为目标文件。
+//
+// [FileKeyStore.Find] 可作为 [SecretFinderFunc] 使用， FileKeyStore 本身也实现了 [SecretProvider] ：
+//
+//	store, err := sigauth.NewFileKeyStore("keys.yaml", sigauth.FileKeyStoreOption{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer store.Close()
+//	mw := sigauth.Middleware(sigauth.SigAuthHandlerOption{SecretFinder: store.Find})
+//
+// 并发安全。
+type FileKeyStore struct {
+	path          string
+	format        KeyFileFormat
+	onReloadError func(err error)
+
+	keys atomic.Pointer[map[string]Secret]
+
+	mu      sync.Mutex // 保护 modTime 和 size ，避免并发重新加载。
+	modTime time.Time
+	size    int64
+
+	stop      chan struct{}
+	done      chan struct{} // 轮询结束后被关闭。
+	closeOnce sync.Once
+}
+
+// NewFileKeyStore 创建 [FileKeyStore] 并加载文件 path 。若首次加载失败，返回 error 。
+func NewFileKeyStore(path string, op FileKeyStoreOption) (*FileKeyStore, error) {
+	format := op.Format
+	if format == KeyFileFormatAuto {
+		format = keyFileFormatOf(path)
+	}
+
+	switch format {
+	case KeyFileFormatJSON, KeyFileFormatYAML, KeyFileFormatEnv:
+	default:
+		return nil, fmt.Errorf("unsupported key file format %q", format)
+	}
+
+	onReloadError := op.OnReloadError
+	if onReloadError == nil {
+		onReloadError = func(err error) {
+			log.Printf("sigauth: reload key file: %v", err)
+		}
+	}
+
+	x := &FileKeyStore{
+		path:          path,
+		format:        format,
+		onReloadError: onReloadError,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if err := x.Reload(); err != nil {
+		return nil, err
+	}
+
+	interval := op.PollInterval
+	if interval == 0 {
+		interval = DefaultKeyFilePollInterval
+	}
+	if interval > 0 {
+		go x.poll(interval)
+	} else {
+		close(x.done)
+	}
+
+	return x, nil
+}
+
+// Find 返回 accessKey 绑定的 secret ，若没有绑定或已被禁用，返回空字符串。签名同 [SecretFinderFunc] 。
+func (x *FileKeyStore) Find(accessKey string) string {
+	s := (*x.keys.Load())[accessKey]
+	if s.Disabled {
+		return ""
+	}
+	return s.Value
+}
+
+// Secret 实现 [SecretProvider] ，返回的 Secret 包含文件中给出的元数据。
+func (x *FileKeyStore) Secret(ctx context.Context, accessKey string) (Secret, error) {
+	s, ok := (*x.keys.Load())[accessKey]
+	if !ok {
+		return Secret{}, ErrUnknownKey
+	}
+	return s, nil
+}
+
+// Reload 立即重新读取文件，不论其是否被修改。若读取或解析失败，返回 error 并保留之前加载的内容。
+func (x *FileKeyStore) Reload() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	info, err := os.Stat(x.path)
+	if err != nil {
+		return err
+	}
+	return x.load(info)
+}
+
+// Close 停止自动重新加载，并等待进行中的重新加载结束。之后仍可使用最后加载的内容。
+func (x *FileKeyStore) Close() error {
+	x.closeOnce.Do(func() {
+		close(x.stop)
+	})
+	<-x.done
+	return nil
+}
+
+func (x *FileKeyStore) poll(interval time.Duration) {
+	defer close(x.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-x.stop:
+			return
+		case <-ticker.C:
+			if err := x.reloadIfModified(); err != nil {
+				x.onReloadError(err)
+			}
+		}
+	}
+}
+
+// 若文件的修改时间或大小发生了变化，重新加载文件。
+func (x *FileKeyStore) reloadIfModified() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	info, err := os.Stat(x.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(x.modTime) && info.Size() == x.size {
+		return nil
+	}
+	return x.load(info)
+}
+
+// 读取并解析文件，成功后替换当前的内容。调用方需持有 mu 。
+func (x *FileKeyStore) load(info os.FileInfo) error {
+	data, err := os.ReadFile(x.path)
+	if err != nil {
+		return err
+	}
+
+	// 读取期间文件被修改时，内容可能不完整，不记录文件的状态，下次轮询时重新读取。
+	after, err := os.Stat(x.path)
+	if err != nil {
+		return err
+	}
+	if !after.ModTime().Equal(info.ModTime()) || after.Size() != info.Size() || after.Size() != int64(len(data)) {
+		return fmt.Errorf("%s was modified while reading", x.path)
+	}
+
+	keys, err := parseKeyFile(data, x.format)
+	if err != nil {
+		// 解析失败时也记录文件的状态，避免每次轮询都重复报告同一个错误。
+		x.modTime, x.size = info.ModTime(), info.Size()
+		return fmt.Errorf("parse %s: %w", x.path, err)
+	}
+
+	x.keys.Store(&keys)
+	x.modTime, x.size = info.ModTime(), info.Size()
+	return nil
+}
+
+func keyFileFormatOf(path string) KeyFileFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return KeyFileFormatJSON
+	case ".yaml", ".yml":
+		return KeyFileFormatYAML
+	default:
+		return KeyFileFormatEnv
+	}
+}
+
+// keyFileEntry 是 JSON 和 YAML 格式中的一项，可以是字符串形式的 secret ，也可以是带元数据的对象。
+type keyFileEntry struct {
+	Secret   string   `json:"secret" yaml:"secret"`
+	Owner    string   `json:"owner" yaml:"owner"`
+	Scopes   []string `json:"scopes" yaml:"scopes"`
+	Disabled bool     `json:"disabled" yaml:"disabled"`
+}
+
+func (x *keyFileEntry) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &x.Secret)
+	}
+
+	type plain keyFileEntry
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(x))
+}
+
+func (x *keyFileEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&x.Secret)
+	}
+
+	// yaml 不支持拒绝未知的字段，逐个检查，使拼写错误（如将 disabled 写为 disable ）不被忽略，与 JSON 的行为一致。
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			key := value.Content[i]
+			if !_keyFileEntryFields[key.Value] {
+				return fmt.Errorf("line %d: unknown field %q", key.Line, key.Value)
+			}
+		}
+	}
+
+	type plain keyFileEntry
+	return value.Decode((*plain)(x))
+}
+
+// keyFileEntry 的字段在 YAML 中的名称。
+var _keyFileEntryFields = map[string]bool{"secret": true, "owner": true, "scopes": true, "disabled": true}
+
+func parseKeyFile(data []byte, format KeyFileFormat) (map[string]Secret, error) {
+	// 空文件通常是正在被写入的文件，而不是要移除所有的 accessKey 。
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("empty key file")
+	}
+
+	var entries map[string]keyFileEntry
+	switch format {
+	case KeyFileFormatJSON:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+
+	case KeyFileFormatYAML:
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+
+	default:
+		var err error
+		if entries, err = parseEnvKeyFile(data); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make(map[string]Secret, len(entries))
+	for key, e := range entries {
+		if key == "" {
+			return nil, errors.New("empty access key")
+		}
+		if e.Secret == "" {
+			return nil, fmt.Errorf("empty secret for access key %q", key)
+		}
+		keys[key] = Secret{Value: e.Secret, Owner: e.Owner, Scopes: e.Scopes, Disabled: e.Disabled}
+	}
+	return keys, nil
+}
+
+func parseEnvKeyFile(data []byte) (map[string]keyFileEntry, error) {
+	entries := make(map[string]keyFileEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+
+		if _, dup := entries[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate access key %q", n, key)
+		}
+		entries[key] = keyFileEntry{Secret: value}
+	}
+	return entries, scanner.Err()
+}
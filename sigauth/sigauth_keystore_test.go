@@ -0,0 +1,161 @@
+package sigauth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileKeyStore_formats(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"JSON", "keys.json", `{"k1": "s1", "k2": {"secret": "s2", "owner": "team-a", "scopes": ["read"]}, "k3": {"secret": "s3", "disabled": true}}`},
+		{"YAML", "keys.yml", "k1: s1\nk2:\n  secret: s2\n  owner: team-a\n  scopes: [read]\nk3:\n  secret: s3\n  disabled: true\n"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), c.file)
+			writeKeyFile(t, path, c.content, time.Now())
+
+			store, err := NewFileKeyStore(path, FileKeyStoreOption{PollInterval: -1})
+			require.NoError(t, err)
+			defer store.Close()
+
+			assert.Equal(t, "s1", store.Find("k1"))
+			assert.Equal(t, "s2", store.Find("k2"))
+			assert.Equal(t, "", store.Find("k3"))
+			assert.Equal(t, "", store.Find("none"))
+
+			s, err := store.Secret(context.Background(), "k2")
+			require.NoError(t, err)
+			assert.Equal(t, Secret{Value: "s2", Owner: "team-a", Scopes: []string{"read"}}, s)
+
+			s, err = store.Secret(context.Background(), "k3")
+			require.NoError(t, err)
+			assert.True(t, s.Disabled)
+
+			_, err = store.Secret(context.Background(), "none")
+			assert.ErrorIs(t, err, ErrUnknownKey)
+		})
+	}
+
+	t.Run("Env", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.env")
+		writeKeyFile(t, path, "# comment\n\nk1=s1\nexport k2 = \"s=2\\n\"\nk3='s3 '\n", time.Now())
+
+		store, err := NewFileKeyStore(path, FileKeyStoreOption{PollInterval: -1})
+		require.NoError(t, err)
+		defer store.Close()
+
+		assert.Equal(t, "s1", store.Find("k1"))
+		assert.Equal(t, "s=2\n", store.Find("k2"))
+		assert.Equal(t, "s3 ", store.Find("k3"))
+	})
+}
+
+func TestFileKeyStore_errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileKeyStore(filepath.Join(dir, "none.json"), FileKeyStoreOption{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = NewFileKeyStore(filepath.Join(dir, "keys"), FileKeyStoreOption{Format: "xml"})
+	assert.Error(t, err)
+
+	invalid := map[string]string{
+		"syntax.json":    `{"k1": `,
+		"unknown.json":   `{"k1": {"secret": "s1", "secrte": "x"}}`,
+		"empty.json":     `{"k1": ""}`,
+		"syntax.yaml":    "k1: [",
+		"unknown.yaml":   "k1:\n  secret: s1\n  disable: true\n",
+		"blank.yaml":     " \n",
+		"missing.env":    "k1\n",
+		"duplicated.env": "k1=s1\nk1=s2\n",
+	}
+	for name, content := range invalid {
+		path := filepath.Join(dir, name)
+		writeKeyFile(t, path, content, time.Now())
+		_, err := NewFileKeyStore(path, FileKeyStoreOption{})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFileKeyStore_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "k1: s1\n", modTime)
+
+	reloadErrs := make(chan error, 10)
+	store, err := NewFileKeyStore(path, FileKeyStoreOption{
+		PollInterval:  10 * time.Millisecond,
+		OnReloadError: func(err error) { reloadErrs <- err },
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// 文件修改后自动重新加载。
+	modTime = modTime.Add(time.Second)
+	writeKeyFile(t, path, "k1: s1-new\nk2: s2\n", modTime)
+	require.Eventually(t, func() bool { return store.Find("k2") == "s2" }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "s1-new", store.Find("k1"))
+
+	// 内容无效时保留之前的内容，并报告错误。
+	modTime = modTime.Add(time.Second)
+	writeKeyFile(t, path, "k1: [", modTime)
+	select {
+	case err := <-reloadErrs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("reload error not reported")
+	}
+	assert.Equal(t, "s1-new", store.Find("k1"))
+
+	// 同一错误不被重复报告。
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, reloadErrs)
+
+	// Close 后不再重新加载，但仍可使用最后加载的内容。
+	require.NoError(t, store.Close())
+	modTime = modTime.Add(time.Second)
+	writeKeyFile(t, path, "k1: s1-closed\n", modTime)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "s1-new", store.Find("k1"))
+
+	// 可手动重新加载。
+	require.NoError(t, store.Reload())
+	assert.Equal(t, "s1-closed", store.Find("k1"))
+}
+
+func TestFileKeyStore_resolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, path, `{"`+_key+`": "`+_secret+`"}`, time.Now())
+
+	store, err := NewFileKeyStore(path, FileKeyStoreOption{PollInterval: -1})
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, op := range []SigAuthHandlerOption{
+		{SecretFinder: store.Find, TimeChecker: NoTimeChecker},
+		{SecretProvider: store, TimeChecker: NoTimeChecker},
+	} {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		_, err := NewSigAuthResolverWithOption(op).Verify(r)
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,104 @@
+package sigauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// 内置的消息认证码算法的名称，用于 Authorization 头的 Algorithm 字段。
+// 带 -B64URL 后缀的算法，签名使用无填充的 base64url 编码，否则使用小写的 HEX 编码。
+const (
+	MacHmacSha256         = "HMAC-SHA256"
+	MacHmacSha512         = "HMAC-SHA512"
+	MacHmacSha3_256       = "HMAC-SHA3-256"
+	MacHmacSha256B64Url   = "HMAC-SHA256-B64URL"
+	MacHmacSha512B64Url   = "HMAC-SHA512-B64URL"
+	MacHmacSha3_256B64Url = "HMAC-SHA3-256-B64URL"
+
+	// 默认的消息认证码算法，当 Authorization 头没有写 Algorithm 字段时，默认为此算法。
+	DefaultMacAlgorithm = MacHmacSha256
+)
+
+// MacAlgorithm 描述一种基于 HMAC 的消息认证码算法，及其结果的编码方式。
+type MacAlgorithm struct {
+	// 算法名称，对应 Authorization 头的 Algorithm 字段。不能包含空格和逗号。
+	Name string
+
+	// HMAC 使用的哈希函数。
+	Hash func() hash.Hash
+
+	// 将 HMAC 的结果编码为 Authorization 头的 Sign 字段的值。
+	Encode func(sum []byte) string
+}
+
+// Mac 计算 data 的 HMAC ，并返回编码后的签名。
+func (x MacAlgorithm) Mac(secret, data []byte) string {
+	h := hmac.New(x.Hash, secret)
+	h.Write(data)
+	return x.Encode(h.Sum(nil))
+}
+
+var (
+	_macAlgorithmsMu sync.RWMutex
+	_macAlgorithms   = map[string]MacAlgorithm{}
+)
+
+func init() {
+	encodeBase64Url := base64.RawURLEncoding.EncodeToString
+	for _, alg := range []MacAlgorithm{
+		{MacHmacSha256, sha256.New, hex.EncodeToString},
+		{MacHmacSha512, sha512.New, hex.EncodeToString},
+		{MacHmacSha3_256, sha3.New256, hex.EncodeToString},
+		{MacHmacSha256B64Url, sha256.New, encodeBase64Url},
+		{MacHmacSha512B64Url, sha512.New, encodeBase64Url},
+		{MacHmacSha3_256B64Url, sha3.New256, encodeBase64Url},
+	} {
+		RegisterMacAlgorithm(alg)
+	}
+}
+
+// RegisterMacAlgorithm 注册消息认证码算法，若同名算法已存在，则替换之。
+// 注册后，签名方和验签方均可通过 Authorization 头的 Algorithm 字段选用。通常应在 init 阶段调用。
+func RegisterMacAlgorithm(alg MacAlgorithm) {
+	if alg.Name == "" || alg.Hash == nil || alg.Encode == nil {
+		panic("Name, Hash and Encode must be provided")
+	}
+
+	_macAlgorithmsMu.Lock()
+	defer _macAlgorithmsMu.Unlock()
+	_macAlgorithms[alg.Name] = alg
+}
+
+// LookupMacAlgorithm 返回给定名称的消息认证码算法，名称为空时返回 [DefaultMacAlgorithm] 。
+// 若没有注册，返回 false 。
+func LookupMacAlgorithm(name string) (MacAlgorithm, bool) {
+	if name == "" {
+		name = DefaultMacAlgorithm
+	}
+
+	_macAlgorithmsMu.RLock()
+	defer _macAlgorithmsMu.RUnlock()
+	alg, ok := _macAlgorithms[name]
+	return alg, ok
+}
+
+// MacAlgorithms 返回所有已注册的消息认证码算法的名称，升序排列。
+func MacAlgorithms() []string {
+	_macAlgorithmsMu.RLock()
+	defer _macAlgorithmsMu.RUnlock()
+
+	names := make([]string, 0, len(_macAlgorithms))
+	for name := range _macAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+package sigauth
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMacAlgorithm(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{MacHmacSha256, "f7138e89b7b6167ee938f0ba9eef0cea4c7080e027bb84ab216acb264fc7d5a3"},
+		{MacHmacSha512, "fd288dbc19896cb0dd7e9be2dcf8fac8d8ba74082951792bb9ab28ecc87d10a4f2272f3f64bca1f84535c1ce88243134ac45dbc1b3fceee6450f5c273f57f84f"},
+		{MacHmacSha3_256, "f60f261d48fdf1e0961385702fbcc97a3d9dfb2327ea48adf5ba18b2e11ed9c8"},
+		{MacHmacSha256B64Url, "9xOOibe2Fn7pOPC6nu8M6kxwgOAnu4SrIWrLJk_H1aM"},
+		{MacHmacSha512B64Url, "_SiNvBmJbLDdfpvi3Pj6yNi6dAgpUXkruaso7Mh9EKTyJy8_ZLyh-EU1wc6IJDE0rEXbwbP87uZFD1wnP1f4Tw"},
+		{MacHmacSha3_256B64Url, "9g8mHUj98eCWE4VwL7zJej2d-yMn6kit9boYsuEe2cg"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			alg, ok := LookupMacAlgorithm(c.name)
+			require.True(t, ok)
+			assert.Equal(t, c.want, alg.Mac([]byte(_secret), []byte("plain to hash")))
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		alg, ok := LookupMacAlgorithm("")
+		require.True(t, ok)
+		assert.Equal(t, MacHmacSha256, alg.Name)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, ok := LookupMacAlgorithm("MD5")
+		assert.False(t, ok)
+	})
+}
+
+// 测试不同算法的签名和验签，及服务端的算法白名单
+func TestSigAuthResolver_algorithms(t *testing.T) {
+	newSignedRequest := func(algorithm string) *http.Request {
+		r := newRequest("", "/p?a=1", _requestTypeForm, "x=1")
+		res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Algorithm: algorithm})
+		require.Equal(t, SignResultType_OK, res.Type)
+		return r
+	}
+
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		Algorithms:   []string{MacHmacSha256, MacHmacSha512B64Url},
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		r := newSignedRequest(MacHmacSha512B64Url)
+		assert.Regexp(t, fmt.Sprintf("Algorithm=%s", MacHmacSha512B64Url), r.Header.Get(HttpHeaderAuthorization))
+
+		auth, err := resolver.Verify(r)
+		require.NoError(t, err)
+		assert.Equal(t, MacHmacSha512B64Url, auth.Algorithm)
+	})
+
+	t.Run("OK-Default", func(t *testing.T) {
+		_, err := resolver.Verify(newSignedRequest(""))
+		require.NoError(t, err)
+	})
+
+	t.Run("NotAllowed", func(t *testing.T) {
+		_, err := resolver.Verify(newSignedRequest(MacHmacSha3_256))
+		require.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Algorithm: "MD5"})
+		assert.Equal(t, SignResultType_UnsupportedAlgorithm, res.Type)
+
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key=%s, Sign=s, Timestamp=1, Algorithm=MD5", DefaultAuthScheme, _key))
+		_, err := NewSigAuthResolver("", finderForTest, NoTimeChecker).Verify(r)
+		require.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	})
+}
@@ -0,0 +1,131 @@
+package sigauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// 在 [context.Context] 中存放数据所用的 key 的类型，避免与其他包冲突。
+type contextKey int
+
+const (
+	_contextKeyAuthorization contextKey = iota
+	_contextKeySecret
+)
+
+// ErrorHandlerFunc 用于在 [Middleware] 验签失败时写入 HTTP 响应， err 为 [sigAuthResolver.Verify] 返回的错误。
+// 被调用前，若错误对应的状态码为 401 ，响应的 WWW-Authenticate 头已被设置。
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultErrorHandler 是默认的 [ErrorHandlerFunc] ，
+// 以 [ErrorStatusCode] 给出的状态码和纯文本的错误描述作为响应。
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	code := ErrorStatusCode(err)
+	msg := err.Error()
+	if code == http.StatusInternalServerError {
+		msg = http.StatusText(code)
+	}
+	http.Error(w, msg, code)
+}
+
+// ErrorStatusCode 返回验签错误对应的 HTTP 状态码：
+//   - [*SignError] 请求本身格式有误，返回 400 ；其中 body 超过长度限制时，返回 413 。
+//   - 签名信息缺失或不正确，返回 401 。
+//   - [ErrKeyDisabled] 、 [ErrScopeNotAllowed] 返回 403 。
+//   - 其他未知错误，返回 500 。
+func ErrorStatusCode(err error) int {
+	var signErr *SignError
+	switch {
+	case errors.As(err, &signErr):
+		if signErr.Type == SignResultType_BodyTooLarge {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusBadRequest
+
+	case errors.Is(err, ErrMissingAuthorization),
+		errors.Is(err, ErrInvalidAuthorization),
+		errors.Is(err, ErrUnsupportedVersion),
+		errors.Is(err, ErrUnsupportedAlgorithm),
+		errors.Is(err, ErrUnknownKey),
+		errors.Is(err, ErrTimestampOutOfRange),
+		errors.Is(err, ErrSignatureMismatch),
+		errors.Is(err, ErrMissingNonce),
+		errors.Is(err, ErrReplayedNonce):
+		return http.StatusUnauthorized
+
+	case errors.Is(err, ErrKeyDisabled),
+		errors.Is(err, ErrScopeNotAllowed):
+		return http.StatusForbidden
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Middleware 返回一个 net/http 中间件，对每个请求进行验签，参数同 [NewSigAuthResolverWithOption] 。
+//   - 验签通过时，将 [Authorization] 存入请求的 [context.Context] ，可通过 [AuthorizationFromContext] 获取；
+//     使用 secret 签名时，其元数据可通过 [SecretFromContext] 获取。
+//   - 验签失败时，交由 [SigAuthHandlerOption.ErrorHandler] 写入响应，不再调用后续的 handler 。
+//   - 使用 X-Content-SHA256 头代替 body 签名时，body 在验签时不被读取，其摘要只在 handler 读到 body 末尾时校验，
+//     读取返回 [ErrBodyDigestMismatch] 。 handler 没有读到末尾（如使用 [encoding/json.Decoder] ）时，被篡改的 body 不会被发现，
+//     此时 handler 需自行读完 body 并检查错误，或设置 [SigAuthHandlerOption.VerifyBodyDigestFirst] 。
+func Middleware(op SigAuthHandlerOption) func(http.Handler) http.Handler {
+	resolver := NewSigAuthResolverWithOption(op)
+
+	errorHandler := op.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler
+	}
+
+	authScheme := op.AuthScheme
+	if authScheme == "" {
+		authScheme = DefaultAuthScheme
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, secret, err := resolver.verifyWithSecret(r)
+			if err == nil && op.VerifyBodyDigestFirst {
+				err = verifyBodyDigestNow(r)
+			}
+			if err != nil {
+				if ErrorStatusCode(err) == http.StatusUnauthorized {
+					w.Header().Set(HttpHeaderWWWAuthenticate, authScheme)
+				}
+				errorHandler(w, r, err)
+				return
+			}
+
+			ctx := ContextWithAuthorization(r.Context(), auth)
+			if !secret.empty() {
+				ctx = contextWithSecret(ctx, secret)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ContextWithAuthorization 返回一个存放了验签通过的 [Authorization] 的 [context.Context] 。
+func ContextWithAuthorization(ctx context.Context, auth *Authorization) context.Context {
+	return context.WithValue(ctx, _contextKeyAuthorization, auth)
+}
+
+// AuthorizationFromContext 获取 [Middleware] 存入的 [Authorization] ，若没有，返回 nil, false 。
+func AuthorizationFromContext(ctx context.Context) (*Authorization, bool) {
+	auth, ok := ctx.Value(_contextKeyAuthorization).(*Authorization)
+	return auth, ok
+}
+
+// 返回一个存放了 [Secret] 元数据的 [context.Context] ， secret 的值被清空，不会传给后续的 handler 。
+func contextWithSecret(ctx context.Context, secret Secret) context.Context {
+	return context.WithValue(ctx, _contextKeySecret, secret.metadata())
+}
+
+// SecretFromContext 获取 [Middleware] 存入的 [Secret] 的元数据（如 Owner 、 Scopes 、 Generation ），
+// 其 Value 、 Versions 字段总是为空。
+// 若没有（如使用公钥验签），返回零值和 false 。
+func SecretFromContext(ctx context.Context) (Secret, bool) {
+	secret, ok := ctx.Value(_contextKeySecret).(Secret)
+	return secret, ok
+}
@@ -0,0 +1,85 @@
+package sigauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	var got *Authorization
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = AuthorizationFromContext(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+
+	handler := Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+	})(next)
+
+	t.Run("OK", func(t *testing.T) {
+		r := newRequest("", "/p?a=1", _requestTypeJson, `{"x":1}`)
+		AppendSign(r, _key, _secret, "", _timestamp)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"x":1}`, w.Body.String())
+		require.NotNil(t, got)
+		assert.Equal(t, _key, got.Key)
+		assert.Equal(t, int64(_timestamp), got.Timestamp)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, DefaultAuthScheme, w.Header().Get(HttpHeaderWWWAuthenticate))
+		assert.Regexp(t, "missing the Authorization header", w.Body.String())
+	})
+
+	t.Run("BadRequest", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeJson, "{}")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		r.Header.Set(HttpHeaderContentType, "bad")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get(HttpHeaderWWWAuthenticate))
+	})
+}
+
+func TestMiddleware_customErrorHandler(t *testing.T) {
+	var gotErr error
+	handler := Middleware(SigAuthHandlerOption{
+		AuthScheme:   "CUSTOM",
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			gotErr = err
+			w.WriteHeader(http.StatusForbidden)
+		},
+	})(http.NotFoundHandler())
+
+	r := newRequest("", "/", _requestTypeGet, "")
+	AppendSign(r, "unknown", _secret, "CUSTOM", _timestamp)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "CUSTOM", w.Header().Get(HttpHeaderWWWAuthenticate))
+	assert.ErrorIs(t, gotErr, ErrUnknownKey)
+}
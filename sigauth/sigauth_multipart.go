@@ -0,0 +1,88 @@
+package sigauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+/* 当前文件提供 multipart/form-data 类型的 body 参与签名的实现。 */
+
+// 一个文件 part 的摘要信息。
+type multipartFileDigest struct {
+	name        string // 表单字段名称。
+	fileName    string // 文件名称。
+	contentType string // part 的 Content-Type ，没有时为空。
+	digest      string // 文件内容的 SHA-256 摘要，小写 HEX 格式。
+}
+
+// 采集 multipart/form-data 的 body ，格式为：
+//   - 首行是普通字段（没有 filename 的 part），通过 appendFields 采集，处理方式同 QUERY 。
+//   - 之后每个文件（有 filename 的 part）一行，为以分号分隔的“字段名称;文件名称;Content-Type;SHA-256 摘要”，
+//     前三项使用 [url.QueryEscape] 编码。文件按字段名称的 UTF-8 字节顺序升序排列，同名字段保持原顺序。
+//
+// 文件内容以原始字节（不处理 Content-Transfer-Encoding ）计算摘要，边读取边计算，不会额外复制文件内容。
+func appendMultipartWithNewLine(buf *bytes.Buffer, body []byte, boundary string, appendFields func(*bytes.Buffer, url.Values)) error {
+	if boundary == "" {
+		return errors.New("missing multipart boundary")
+	}
+
+	fields := make(url.Values)
+	var files []multipartFileDigest
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			fields.Add(name, string(value))
+			continue
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, part); err != nil {
+			return err
+		}
+
+		files = append(files, multipartFileDigest{
+			name:        name,
+			fileName:    part.FileName(),
+			contentType: part.Header.Get(HttpHeaderContentType),
+			digest:      hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	appendFields(buf, fields)
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].name < files[j].name
+	})
+
+	for _, f := range files {
+		buf.WriteString(strings.Join([]string{
+			url.QueryEscape(f.name),
+			url.QueryEscape(f.fileName),
+			url.QueryEscape(f.contentType),
+			f.digest,
+		}, ";"))
+		buf.WriteRune('\n')
+	}
+	return nil
+}
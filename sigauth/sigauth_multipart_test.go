@@ -0,0 +1,116 @@
+package sigauth
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultipartRequest(t *testing.T, build func(w *multipart.Writer)) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	build(w)
+	require.NoError(t, w.Close())
+
+	r, err := http.NewRequest(http.MethodPost, "http://temp.org/upload", &body)
+	require.NoError(t, err)
+	r.Header.Set(HttpHeaderContentType, w.FormDataContentType())
+	return r
+}
+
+func writeMultipartFile(t *testing.T, w *multipart.Writer, name, fileName, contentType, content string) {
+	h := make(textproto.MIMEHeader)
+	h.Set(HttpHeaderContentDisposition, `form-data; name="`+name+`"; filename="`+fileName+`"`)
+	if contentType != "" {
+		h.Set(HttpHeaderContentType, contentType)
+	}
+	part, err := w.CreatePart(h)
+	require.NoError(t, err)
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+}
+
+func Test_buildDataToSign_multipart(t *testing.T) {
+	r := newMultipartRequest(t, func(w *multipart.Writer) {
+		w.WriteField("b", "2")
+		writeMultipartFile(t, w, "z", "z.txt", "", "")
+		writeMultipartFile(t, w, "f", "a;b.txt", "text/plain", "abc")
+		w.WriteField("a", "1")
+	})
+
+	data, typ, err := buildDataToSign(r, true, Authorization{Timestamp: 12345}, SignOption{})
+	require.NoError(t, err)
+	assert.Equal(t, SignResultType_OK, typ)
+
+	want := "12345\nPOST\n/upload\n\n" +
+		"12\n" +
+		"f;a%3Bb.txt;text%2Fplain;" + _sha256OfAbc + "\n" +
+		"z;z.txt;;e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n" +
+		"END"
+	assert.Equal(t, want, string(data))
+
+	// 签名后 body 仍可被读取解析。
+	require.NoError(t, r.ParseMultipartForm(1<<20))
+	assert.Equal(t, "1", r.FormValue("a"))
+}
+
+func Test_buildDataToSign_multipartErrors(t *testing.T) {
+	t.Run("MissingBoundary", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "abc")
+		r.Method = http.MethodPost
+		r.Header.Set(HttpHeaderContentType, ContentTypeMultipartForm)
+
+		_, typ, err := buildDataToSign(r, false, Authorization{}, SignOption{})
+		assert.Equal(t, SignResultType_InvalidRequestBody, typ)
+		assert.Error(t, err)
+	})
+
+	t.Run("BadBody", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "not multipart")
+		r.Method = http.MethodPost
+		r.Header.Set(HttpHeaderContentType, ContentTypeMultipartForm+"; boundary=xyz")
+
+		_, typ, err := buildDataToSign(r, false, Authorization{}, SignOption{})
+		assert.Equal(t, SignResultType_InvalidRequestBody, typ)
+		assert.Error(t, err)
+	})
+}
+
+func TestSigAuthHandler_multipart(t *testing.T) {
+	s := newTestServer(SigAuthHandlerOption{
+		TimeChecker: NoTimeChecker,
+	})
+	defer s.Close()
+
+	build := func(content string) func(w *multipart.Writer) {
+		return func(w *multipart.Writer) {
+			w.WriteField("a", "1")
+			writeMultipartFile(t, w, "f", "f.bin", "application/octet-stream", content)
+		}
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := newMultipartRequest(t, build("abc"))
+		r.URL, _ = r.URL.Parse(s.URL + "/upload")
+		r.Host = ""
+		require.Equal(t, SignResultType_OK, AppendSign(r, _key, _secret, "", _timestamp).Type)
+		testRequest(t, r, `{"Code":0,"Message":"","Data":1}`)
+	})
+
+	t.Run("TamperedFile", func(t *testing.T) {
+		signed := newMultipartRequest(t, build("abc"))
+		require.Equal(t, SignResultType_OK, AppendSign(signed, _key, _secret, "", _timestamp).Type)
+
+		r := newMultipartRequest(t, build("abd"))
+		r.URL, _ = r.URL.Parse(s.URL + "/upload")
+		r.Host = ""
+		r.Header.Set(HttpHeaderAuthorization, signed.Header.Get(HttpHeaderAuthorization))
+		// boundary 随机生成，沿用已签名请求的 Content-Type 会导致解析失败，因此使用各自的。
+		testRequest(t, r, `{"Code":400,"Message":"signature mismatch","Data":null}`)
+	})
+}
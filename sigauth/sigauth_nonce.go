@@ -0,0 +1,108 @@
+package sigauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// NonceStore 记录已经使用过的 nonce ，用于拒绝被重放的请求。实现需是并发安全的。
+type NonceStore interface {
+	// Use 记录 accessKey 对应的 nonce 已被使用。
+	// 若此 nonce 是首次出现，返回 true ；若在有效期内已经出现过，返回 false 。
+	// 返回 error 表示存储本身出错，此时验签失败。
+	Use(accessKey, nonce string) (bool, error)
+}
+
+// ExpiringNonceStore 是可以按签名的有效期保留 nonce 的 [NonceStore] 。
+// 带 Expires 的签名在 Expires 之前均可通过验签，其 nonce 需保留到 Expires 之后，才能防止重放。
+// 配置的 NonceStore 未实现此接口时，带 Expires 的签名验签失败。
+type ExpiringNonceStore interface {
+	NonceStore
+
+	// UseUntil 同 Use ，但 nonce 至少被保留到 until 。
+	UseUntil(accessKey, nonce string, until time.Time) (bool, error)
+}
+
+// 内存 NonceStore 的分片数，降低锁竞争。
+const _nonceStoreShardCount = 32
+
+// MemoryNonceStore 是基于内存的 [ExpiringNonceStore] ，每个 nonce 被记录 ttl 时长，过期后自动清理。
+// 数据按 key 分片存储，各分片独立加锁。仅适用于单个服务实例，多实例部署时需要基于共享存储的实现。
+type MemoryNonceStore struct {
+	ttl    time.Duration
+	shards [_nonceStoreShardCount]nonceStoreShard
+}
+
+type nonceStoreShard struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time // nonce 的过期时间。
+	nextSweep time.Time            // 下次清理过期 nonce 的时间。
+}
+
+// NewMemoryNonceStore 创建 [MemoryNonceStore] ，每个 nonce 被记录 ttl 时长。
+// ttl 应覆盖时间戳校验所允许的整个范围，如对于 MaxDeviationTimeChecker(300) ，ttl 至少为 600 秒，
+// 否则在时间戳仍有效时， nonce 可能已被清理，请求可以被再次重放。
+// 带 Expires 的签名的 nonce 通过 UseUntil 保留到 Expires ，不受 ttl 的限制。
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	if ttl <= 0 {
+		panic("ttl must be positive")
+	}
+
+	x := &MemoryNonceStore{ttl: ttl}
+	for i := range x.shards {
+		x.shards[i].expires = make(map[string]time.Time)
+	}
+	return x
+}
+
+// Use 实现 [NonceStore] ，总是返回 nil error 。
+func (x *MemoryNonceStore) Use(accessKey, nonce string) (bool, error) {
+	return x.use(accessKey+"\x00"+nonce, time.Now(), time.Time{}), nil
+}
+
+// UseUntil 实现 [ExpiringNonceStore] ， nonce 被记录到 ttl 之后和 until 中较晚的时间，总是返回 nil error 。
+func (x *MemoryNonceStore) UseUntil(accessKey, nonce string, until time.Time) (bool, error) {
+	return x.use(accessKey+"\x00"+nonce, time.Now(), until), nil
+}
+
+func (x *MemoryNonceStore) use(key string, now, until time.Time) bool {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &x.shards[h.Sum32()%_nonceStoreShardCount]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// 惰性清理，每个 ttl 周期最多扫描一次。
+	if now.After(shard.nextSweep) {
+		for k, exp := range shard.expires {
+			if !exp.After(now) {
+				delete(shard.expires, k)
+			}
+		}
+		shard.nextSweep = now.Add(x.ttl)
+	}
+
+	if exp, ok := shard.expires[key]; ok && exp.After(now) {
+		return false
+	}
+
+	exp := now.Add(x.ttl)
+	if until.After(exp) {
+		exp = until
+	}
+	shard.expires[key] = exp
+	return true
+}
+
+// RandomNonce 返回一个 32 个字符的随机 HEX 串，可用作 [Authorization.Nonce] 。
+func RandomNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
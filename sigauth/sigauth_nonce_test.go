@@ -0,0 +1,148 @@
+package sigauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	now := time.Unix(_timestamp, 0)
+
+	assert.True(t, store.use("k\x00n1", now, time.Time{}))
+	assert.False(t, store.use("k\x00n1", now.Add(30*time.Second), time.Time{}))
+	assert.True(t, store.use("k\x00n2", now, time.Time{}))
+
+	// 过期后可再次使用。
+	assert.True(t, store.use("k\x00n1", now.Add(2*time.Minute), time.Time{}))
+
+	ok, err := store.Use("k1", "n")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, _ = store.Use("k2", "n")
+	assert.True(t, ok, "nonce is scoped by access key")
+
+	ok, _ = store.Use("k1", "n")
+	assert.False(t, ok)
+}
+
+func TestMemoryNonceStore_until(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	now := time.Unix(_timestamp, 0)
+
+	// 保留到 until ，而不是 ttl 之后。
+	assert.True(t, store.use("k\x00n1", now, now.Add(time.Hour)))
+	assert.False(t, store.use("k\x00n1", now.Add(30*time.Minute), time.Time{}))
+	assert.True(t, store.use("k\x00n1", now.Add(61*time.Minute), time.Time{}))
+
+	// until 早于 ttl 时，仍保留 ttl 时长。
+	assert.True(t, store.use("k\x00n2", now, now.Add(time.Second)))
+	assert.False(t, store.use("k\x00n2", now.Add(30*time.Second), time.Time{}))
+}
+
+func TestSigAuthResolver_nonce(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		MaxLifetime:  DefaultMaxLifetime,
+		NonceStore:   NewMemoryNonceStore(10 * time.Minute),
+	})
+
+	t.Run("MissingNonce", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, _key, _secret, "", _timestamp)
+
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrMissingNonce)
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		r := newRequest("", "/p?a=1", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:       _key,
+			Timestamp: _timestamp,
+			Nonce:     RandomNonce(),
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		auth, err := resolver.Verify(r)
+		require.NoError(t, err)
+		assert.NotEmpty(t, auth.Nonce)
+
+		_, err = resolver.Verify(r)
+		require.ErrorIs(t, err, ErrReplayedNonce)
+	})
+
+	t.Run("TamperedNonce", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:       _key,
+			Timestamp: _timestamp,
+			Nonce:     "n1",
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+		r.Header.Set(HttpHeaderAuthorization, BuildAuthorizationHeader(Authorization{
+			Key:       _key,
+			Sign:      res.Sign,
+			Timestamp: _timestamp,
+			Nonce:     "n2",
+		}))
+
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		now := time.Now().Unix()
+		r := newRequest("", "/", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:       _key,
+			Timestamp: now,
+			Expires:   now + 3600,
+			Nonce:     RandomNonce(),
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		_, err := resolver.Verify(r)
+		require.NoError(t, err)
+
+		// nonce 被保留到 Expires ，而不是 10 分钟。
+		auth, err := ParseAuthorizationHeader(r, "")
+		require.NoError(t, err)
+		store := resolver.nonceStore.(*MemoryNonceStore)
+		assert.False(t, store.use(auth.Key+"\x00"+auth.Nonce, time.Unix(now+3000, 0), time.Time{}))
+	})
+
+	t.Run("ExpiresWithoutExpiringStore", func(t *testing.T) {
+		resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+			SecretFinder: finderForTest,
+			TimeChecker:  NoTimeChecker,
+			MaxLifetime:  DefaultMaxLifetime,
+			NonceStore:   plainNonceStore{NewMemoryNonceStore(10 * time.Minute)},
+		})
+
+		now := time.Now().Unix()
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: now, Expires: now + 3600, Nonce: RandomNonce()})
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrTimestampOutOfRange)
+
+		r = newRequest("", "/", _requestTypeGet, "")
+		AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: now, Nonce: RandomNonce()})
+		_, err = resolver.Verify(r)
+		require.NoError(t, err)
+	})
+}
+
+// 只实现 NonceStore 的存储。
+type plainNonceStore struct {
+	store *MemoryNonceStore
+}
+
+func (x plainNonceStore) Use(accessKey, nonce string) (bool, error) {
+	return x.store.Use(accessKey, nonce)
+}
@@ -0,0 +1,59 @@
+package sigauth
+
+import "net/http"
+
+// SignOption 给出待签名串的构建选项，签名方和验签方需使用相同的选项，零值即默认行为。
+// 服务端通过 [SigAuthHandlerOption] 给出，客户端通过 [SignWith] 等方法的可选参数或 [Transport] 给出。
+type SignOption struct {
+	// 总是签名 body 的 HTTP 方法，如 DELETE 、 PURGE ，区分大小写。若为空，则为 POST 、 PUT 、 PATCH 。
+	// v2 起，不在其中的方法，当请求实际带有非空的 body 时，同样签名 body ；v1 则不签名其 body 。
+	BodyMethods []string
+
+	// 是否签名未知类型的 body 。若为 true ，则 Content-Type 不受支持的 body 使用其原始内容的 SHA-256 摘要签名；
+	// 否则返回 [SignResultType_UnsupportedContentType] 。仅用于 v2 起的版本， v1 不支持以摘要签名 body 。
+	SignUnknownContentTypes bool
+
+	// 是否使用编码后的路径（ [url.URL.EscapedPath] ）签名 PATH 部分。若为 true ，路径按 [normalizeEscapedPath] 规范化，
+	// 此时“/a%2Fb”与“/a/b”不同，“/a/%7Eb”与“/a/~b”相同；否则使用解码后的 [url.URL.Path] 。
+	// 规范化规则需与服务前的代理对路径的处理相匹配。
+	EscapedPath bool
+
+	// EscapedPath 为 true 时，对路径末尾的“/”的处理方式。
+	TrailingSlash TrailingSlashRule
+
+	// 是否签名请求的 authority （ host 和端口），使签名不能用于其他主机，如将测试环境的签名用于生产环境。
+	// authority 会转为小写，并去掉默认端口。服务端在 TLS 终止代理之后时，需配置 [SigAuthHandlerOption.TrustedProxies] 。
+	SignHost bool
+
+	// 是否签名请求的 scheme （ http 或 https ）。
+	SignScheme bool
+
+	// 由验签方给出的请求的 scheme 和 host （来自可信代理的转发头），为空时从请求获取。
+	scheme, host string
+}
+
+// 默认总是签名 body 的 HTTP 方法。
+var _defaultBodyMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch}
+
+// 判断给定的 HTTP 方法是否总是签名 body 。
+func (x SignOption) isBodyMethod(method string) bool {
+	methods := x.BodyMethods
+	if len(methods) == 0 {
+		methods = _defaultBodyMethods
+	}
+
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回可选参数中的 SignOption ，未给出时为零值。
+func signOptionOf(opt []SignOption) SignOption {
+	if len(opt) == 0 {
+		return SignOption{}
+	}
+	return opt[0]
+}
@@ -0,0 +1,114 @@
+package sigauth
+
+import "strings"
+
+/* 当前文件提供 PATH 部分的规范化实现。 */
+
+// TrailingSlashRule 指定规范化路径时，对路径末尾的“/”的处理方式。
+type TrailingSlashRule int
+
+const (
+	TrailingSlashKeep   TrailingSlashRule = iota // 保留末尾的“/”，“/a/”与“/a”不同。
+	TrailingSlashRemove                          // 去掉末尾的“/”（根路径“/”除外），“/a/”与“/a”相同。
+)
+
+// 规范化编码后的路径（ [url.URL.EscapedPath] ），依次：
+//   - 空路径视为“/”。
+//   - 百分号编码中的 HEX 转为大写；编码后为非保留字符（ A-Z 、 a-z 、 0-9 、“-”、“.”、“_”、“~”）的，解码为原字符。
+//   - 按 RFC 3986 5.2.4 移除“.”和“..”路径段。
+//   - 按 trailingSlash 处理末尾的“/”。
+//
+// 其余字节保持原样，如“%2F”不被解码，故“/a%2Fb”与“/a/b”不同。不以“/”开头的路径（如“*”）不做处理。
+func normalizeEscapedPath(p string, trailingSlash TrailingSlashRule) string {
+	if p == "" {
+		return "/"
+	}
+
+	if p[0] != '/' {
+		return p
+	}
+
+	p = removeDotSegments(normalizePercentEncoding(p))
+
+	if trailingSlash == TrailingSlashRemove {
+		p = strings.TrimRight(p, "/")
+		if p == "" {
+			p = "/"
+		}
+	}
+	return p
+}
+
+// 将百分号编码中的 HEX 转为大写，并解码非保留字符。格式错误的编码保持原样。
+func normalizePercentEncoding(p string) string {
+	if strings.IndexByte(p, '%') < 0 {
+		return p
+	}
+
+	const hexChars = "0123456789ABCDEF"
+
+	b := make([]byte, 0, len(p))
+	for i := 0; i < len(p); i++ {
+		if p[i] != '%' || i+2 >= len(p) || !isHex(p[i+1]) || !isHex(p[i+2]) {
+			b = append(b, p[i])
+			continue
+		}
+
+		c := unhex(p[i+1])<<4 | unhex(p[i+2])
+		if isUnreserved(c) {
+			b = append(b, c)
+		} else {
+			b = append(b, '%', hexChars[c>>4], hexChars[c&0x0f])
+		}
+		i += 2
+	}
+	return string(b)
+}
+
+// 按 RFC 3986 5.2.4 移除“.”和“..”路径段， p 需以“/”开头。
+// 以“.”或“..”结尾时，结果以“/”结尾，如“/a/b/..”得到“/a/”。
+func removeDotSegments(p string) string {
+	segments := strings.Split(p[1:], "/")
+	out := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+
+		default:
+			out = append(out, seg)
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,104 @@
+package sigauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_normalizeEscapedPath(t *testing.T) {
+	cases := []struct {
+		path string
+		rule TrailingSlashRule
+		want string
+	}{
+		{"", TrailingSlashKeep, "/"},
+		{"/", TrailingSlashKeep, "/"},
+		{"/", TrailingSlashRemove, "/"},
+		{"//", TrailingSlashRemove, "/"},
+		{"/a/b/", TrailingSlashKeep, "/a/b/"},
+		{"/a/b/", TrailingSlashRemove, "/a/b"},
+		{"/a%2fb", TrailingSlashKeep, "/a%2Fb"},
+		{"/a%2Fb", TrailingSlashKeep, "/a%2Fb"},
+		{"/%7Ea/%41%2d", TrailingSlashKeep, "/~a/A-"},
+		{"/a%e4%b8%ad", TrailingSlashKeep, "/a%E4%B8%AD"},
+		{"/a/./b", TrailingSlashKeep, "/a/b"},
+		{"/a/b/../c", TrailingSlashKeep, "/a/c"},
+		{"/a/b/..", TrailingSlashKeep, "/a/"},
+		{"/a/b/..", TrailingSlashRemove, "/a"},
+		{"/a/%2E%2E/b", TrailingSlashKeep, "/b"},
+		{"/../../a", TrailingSlashKeep, "/a"},
+		{"/a//b", TrailingSlashKeep, "/a//b"},
+		{"/a%", TrailingSlashKeep, "/a%"},
+		{"/a%zz", TrailingSlashKeep, "/a%zz"},
+		{"*", TrailingSlashKeep, "*"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.want, normalizeEscapedPath(c.path, c.rule), c.path)
+	}
+}
+
+func Test_buildDataToSign_escapedPath(t *testing.T) {
+	pathOf := func(rawURL string, opt SignOption) string {
+		r, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		require.NoError(t, err)
+
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, opt)
+		require.NoError(t, err)
+		require.Equal(t, SignResultType_OK, typ)
+		return string(data)
+	}
+
+	// 默认使用解码后的路径。
+	assert.Equal(t, pathOf("http://temp.org/a%2Fb", SignOption{}), pathOf("http://temp.org/a/b", SignOption{}))
+
+	opt := SignOption{EscapedPath: true}
+	assert.Equal(t, "12345\nGET\n/a%2Fb\n\nEND", pathOf("http://temp.org/a%2Fb", opt))
+	assert.NotEqual(t, pathOf("http://temp.org/a%2Fb", opt), pathOf("http://temp.org/a/b", opt))
+
+	// 代理重新编码路径后，签名不变。
+	assert.Equal(t, pathOf("http://temp.org/a%2fb/~c", opt), pathOf("http://temp.org/a%2Fb/%7Ec", opt))
+
+	opt.TrailingSlash = TrailingSlashRemove
+	assert.Equal(t, pathOf("http://temp.org/a/b/", opt), pathOf("http://temp.org/a/b", opt))
+}
+
+func TestSigAuthResolver_escapedPath(t *testing.T) {
+	opt := SignOption{EscapedPath: true}
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		SignOption:   opt,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath()))
+	})))
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: &Transport{AccessKey: _key, Secret: _secret, SignOption: opt},
+	}
+
+	res, err := client.Get(s.URL + "/files/a%2Fb.txt")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "/files/a%2Fb.txt", string(body))
+
+	// 签名的是“/a%2Fb”，请求“/a/b”时签名不匹配。
+	signed, _ := http.NewRequest(http.MethodGet, s.URL+"/a%2Fb", nil)
+	require.Equal(t, SignResultType_OK, AppendSignWith(signed, _secret, Authorization{Key: _key, Timestamp: 1}, opt).Type)
+
+	r, _ := http.NewRequest(http.MethodGet, s.URL+"/a/b", nil)
+	r.Header = signed.Header.Clone()
+	res2, err := http.DefaultClient.Do(r)
+	require.NoError(t, err)
+	res2.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res2.StatusCode)
+}
@@ -0,0 +1,56 @@
+package sigauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PresignURL 生成带签名的 URL ，签名以 ~auth 参数附加在 URL 上，可直接分享给没有 secret 的一方使用，
+// 在 expires 之后失效。服务端按 Authorization 的 Expires 字段校验有效期，而不是时间戳的误差范围，
+// 有效期不能超过 [SigAuthHandlerOption.MaxLifetime] ，服务端需给定此字段才接受这类签名。
+//   - method 请求使用的 HTTP 方法，通常是 GET 。签名中的 body 为空，故只适用于不带 body 的请求。
+//   - rawURL 完整的请求地址，可带有 query ，不能带有 ~auth 参数。
+//   - accessKey 对应 Authorization 头中的 Key 字段的值。
+//   - secret HMAC-SHA256 的密钥，使用 UTF-8 字符集。
+//   - expires 有效期，向上取整到秒，需大于 0 。
+//
+// 签名使用 [SignVersion5] ，以免 URL 上的参数被改写。
+func PresignURL(method, rawURL, accessKey, secret string, expires time.Duration) (string, error) {
+	if expires <= 0 {
+		return "", errors.New("expires must be positive")
+	}
+
+	r, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if r.URL.Query().Has(_metaParamAuth) {
+		return "", errors.New("the URL already has the " + _metaParamAuth + " parameter")
+	}
+
+	timestamp := time.Now().Unix()
+	auth := Authorization{
+		Key:       accessKey,
+		Timestamp: timestamp,
+		Expires:   timestamp + int64((expires+time.Second-1)/time.Second),
+		Version:   SignVersion5,
+	}
+
+	res := SignWith(r, false, secret, auth)
+	if err := res.err(); err != nil {
+		return "", err
+	}
+	auth.Sign = res.Sign
+
+	u := *r.URL
+	param := _metaParamAuth + "=" + url.QueryEscape(BuildAuthorizationHeader(auth))
+	if u.RawQuery == "" {
+		u.RawQuery = param
+	} else {
+		u.RawQuery += "&" + param
+	}
+	return u.String(), nil
+}
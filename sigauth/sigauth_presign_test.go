@@ -0,0 +1,65 @@
+package sigauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresignURL(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		MaxLifetime:  DefaultMaxLifetime,
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		link, err := PresignURL(http.MethodGet, "http://temp.org/files/a.txt?b=2&a=1#frag", _key, _secret, 10*time.Minute)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(link, "http://temp.org/files/a.txt?b=2&a=1&~auth=SIG-AUTH+Key%3DtestKey"), link)
+		assert.True(t, strings.HasSuffix(link, "#frag"), link)
+
+		// 片段不会被发送到服务端。
+		r := httptest.NewRequest(http.MethodGet, strings.TrimSuffix(link, "#frag"), nil)
+		auth, err := resolver.Verify(r)
+		require.NoError(t, err)
+		assert.Equal(t, SignVersion5, auth.Version)
+		assert.Equal(t, auth.Timestamp+600, auth.Expires)
+	})
+
+	t.Run("TamperedQuery", func(t *testing.T) {
+		link, err := PresignURL(http.MethodGet, "http://temp.org/p?a=xy", _key, _secret, time.Minute)
+		require.NoError(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, strings.Replace(link, "a=xy", "a=x&b=y", 1), nil)
+		_, err = resolver.Verify(r)
+		assert.ErrorIs(t, err, ErrSignatureMismatch)
+
+		r = httptest.NewRequest(http.MethodDelete, link, nil)
+		_, err = resolver.Verify(r)
+		assert.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("LifetimeTooLong", func(t *testing.T) {
+		link, err := PresignURL(http.MethodGet, "http://temp.org/p", _key, _secret, 8*24*time.Hour)
+		require.NoError(t, err)
+
+		_, err = resolver.Verify(httptest.NewRequest(http.MethodGet, link, nil))
+		assert.ErrorIs(t, err, ErrTimestampOutOfRange)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := PresignURL(http.MethodGet, "http://temp.org/p", _key, _secret, 0)
+		assert.Error(t, err)
+
+		_, err = PresignURL(http.MethodGet, "http://temp.org/p?~auth=x", _key, _secret, time.Minute)
+		assert.Error(t, err)
+
+		_, err = PresignURL(http.MethodGet, "http://temp.org/%zz", _key, _secret, time.Minute)
+		assert.Error(t, err)
+	})
+}
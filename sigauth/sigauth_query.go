@@ -0,0 +1,66 @@
+package sigauth
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+)
+
+/* 当前文件提供 v5 起使用的规范化 query 的实现。 */
+
+// 采集规范化的 query 键值对 或者 form body 键值对，格式类似 AWS SigV4 的 canonical query string ：
+//   - 参数名称和值分别经 [uriEncode] 编码，以“=”连接；没有值的参数，如“?a”，与“?a=”相同。
+//   - 参数按编码后的名称的字节顺序升序排列，使用稳定的排序算法，同名参数保持原顺序。
+//   - 各参数以“&”连接。没有参数时为空字符串。
+//
+// 名称和值中的“=”、“&”等分隔符均被编码，故不同的参数集合总是得到不同的结果。
+func appendCanonicalQueryWithNewLine(buf *bytes.Buffer, fromUrl bool, query url.Values) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if fromUrl && k == _metaParamAuth {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	// 编码后再排序，使结果只取决于编码后的字节。
+	encodedKeys := make(map[string]string, len(keys))
+	for _, k := range keys {
+		encodedKeys[k] = uriEncode(k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return encodedKeys[keys[i]] < encodedKeys[keys[j]]
+	})
+
+	first := true
+	for _, k := range keys {
+		for _, v := range query[k] {
+			if !first {
+				buf.WriteByte('&')
+			}
+			first = false
+
+			buf.WriteString(encodedKeys[k])
+			buf.WriteByte('=')
+			buf.WriteString(uriEncode(v))
+		}
+	}
+	buf.WriteRune('\n')
+}
+
+// 按 RFC 3986 编码：非保留字符（ A-Z 、 a-z 、 0-9 、“-”、“.”、“_”、“~”）保持原样，
+// 其余字节编码为“%XY”，其中 XY 为大写的 HEX 。空格编码为“%20”，而不是“+”。
+func uriEncode(s string) string {
+	const hexChars = "0123456789ABCDEF"
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isUnreserved(c) {
+			b = append(b, c)
+			continue
+		}
+		b = append(b, '%', hexChars[c>>4], hexChars[c&0x0f])
+	}
+	return string(b)
+}
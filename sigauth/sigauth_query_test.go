@@ -0,0 +1,173 @@
+package sigauth
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 用于检查规范化 query 是否有歧义的测试向量，其中包含 v1 下签名相同的组合。
+var _queryVectors = []string{
+	"",
+	"a",
+	"a=",
+	"a=a",
+	"a=xy",
+	"a=x&b=y",
+	"a=x&b=y&",
+	"ab=",
+	"a=b",
+	"b=a",
+	"a=b&c",
+	"a=b%26c",
+	"a=b%3Dc",
+	"a%3Db=c",
+	"a=b=c",
+	"a%3D=b",
+	"a&b",
+	"a=&b=",
+	"a=1&a=2",
+	"a=2&a=1",
+	"a=12",
+	"a=1&b=2",
+	"b=1&a=2",
+	"a=%20",
+	"a=+",
+	"a=%2B",
+	"a=%25",
+	"a=%2520",
+	"A=1",
+	"a=1",
+	"a=%C3%A9",
+	"a=%E9",
+	"~a=1",
+	"a.b=1",
+	"a-b=1",
+	"a_b=1",
+	"a=%0A",
+	"a=%0Ab=1",
+	"a=1%0A&b=1",
+}
+
+func TestAppendCanonicalQueryWithNewLine_noCollision(t *testing.T) {
+	canonical := func(raw string) (url.Values, string) {
+		values, err := url.ParseQuery(raw)
+		require.NoError(t, err, raw)
+
+		buf := new(bytes.Buffer)
+		appendCanonicalQueryWithNewLine(buf, false, values)
+		return values, buf.String()
+	}
+
+	for i, a := range _queryVectors {
+		va, ca := canonical(a)
+		for _, b := range _queryVectors[i+1:] {
+			vb, cb := canonical(b)
+
+			// 解析结果相同的 query （如“a”和“a=”）视为相同的参数集合。
+			if reflect.DeepEqual(va, vb) {
+				assert.Equal(t, ca, cb, "%q vs %q", a, b)
+			} else {
+				assert.NotEqual(t, ca, cb, "%q vs %q", a, b)
+			}
+		}
+	}
+}
+
+func TestAppendCanonicalQueryWithNewLine(t *testing.T) {
+	cases := []struct {
+		raw, want string
+	}{
+		{"", "\n"},
+		{"a", "a=\n"},
+		{"a=xy", "a=xy\n"},
+		{"b=y&a=x", "a=x&b=y\n"},
+		{"a=2&B=3&a=1", "B=3&a=2&a=1\n"},
+		{"a=b%26c%3Dd", "a=b%26c%3Dd\n"},
+		{"k%20=v+w", "k%20=v%20w\n"},
+		{"x=-_.~!*'()", "x=-_.~%21%2A%27%28%29\n"},
+		{"x=%C3%A9", "x=%C3%A9\n"},
+	}
+
+	for _, c := range cases {
+		values, err := url.ParseQuery(c.raw)
+		require.NoError(t, err)
+
+		buf := new(bytes.Buffer)
+		appendCanonicalQueryWithNewLine(buf, false, values)
+		assert.Equal(t, c.want, buf.String(), c.raw)
+	}
+
+	t.Run("MetaParamAuth", func(t *testing.T) {
+		values, _ := url.ParseQuery("a=1&~auth=x")
+
+		buf := new(bytes.Buffer)
+		appendCanonicalQueryWithNewLine(buf, true, values)
+		assert.Equal(t, "a=1\n", buf.String())
+
+		buf.Reset()
+		appendCanonicalQueryWithNewLine(buf, false, values)
+		assert.Equal(t, "a=1&~auth=x\n", buf.String())
+	})
+}
+
+func Test_buildDataToSign_v5(t *testing.T) {
+	auth := Authorization{Timestamp: 12345, Version: SignVersion5}
+
+	t.Run("Query", func(t *testing.T) {
+		r := newRequest("", "/p?b=y&a=x", _requestTypeGet, "")
+		data, typ, err := buildDataToSign(r, false, auth, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "SIGAUTH-V5\n12345\nGET\n/p\na=x&b=y\n\nEND", string(data))
+	})
+
+	t.Run("Form", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeForm, "b=2&a=1")
+		data, typ, err := buildDataToSign(r, false, auth, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "SIGAUTH-V5\n12345\nPOST\n/p\n\n\nform:a=1&b=2\nEND", string(data))
+	})
+
+	t.Run("SmugglingDetected", func(t *testing.T) {
+		signed := newRequest("", "/p?a=xy", _requestTypeGet, "")
+		smuggled := newRequest("", "/p?a=x&b=y", _requestTypeGet, "")
+
+		v1 := Authorization{Timestamp: 12345}
+		d1, _, _ := buildDataToSign(signed, false, v1, SignOption{})
+		d2, _, _ := buildDataToSign(smuggled, false, v1, SignOption{})
+		assert.Equal(t, string(d1), string(d2))
+
+		d1, _, _ = buildDataToSign(signed, false, auth, SignOption{})
+		d2, _, _ = buildDataToSign(smuggled, false, auth, SignOption{})
+		assert.NotEqual(t, string(d1), string(d2))
+	})
+}
+
+func TestSigAuthResolver_v5(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		Versions:     []int{SignVersion5},
+	})
+
+	r, _ := http.NewRequest(http.MethodGet, "http://temp.org/p?a=xy", nil)
+	require.Equal(t, SignResultType_OK, AppendSignWith(r, _secret, Authorization{
+		Key:       _key,
+		Timestamp: _timestamp,
+		Version:   SignVersion5,
+	}).Type)
+
+	_, err := resolver.Verify(r)
+	require.NoError(t, err)
+
+	r.URL.RawQuery = "a=x&b=y"
+	_, err = resolver.Verify(r)
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+}
@@ -1,71 +1,386 @@
 package sigauth
 
 import (
+	"crypto"
+	"crypto/hmac"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // 解签对象
 type sigAuthResolver struct {
-	authScheme   string
-	secretFinder SecretFinderFunc
-	timeChecker  TimeCheckerFunc
+	authScheme      string
+	secretProvider  SecretProvider
+	publicKeyFinder PublicKeyFinderFunc
+	timeChecker     ExpiryTimeCheckerFunc
+	maxLifetime     int64           // 为 0 时不接受带 Expires 的签名，为负数时不限制。
+	versions        map[int]bool    // 接受的签名算法版本，为 nil 时接受所有已注册的版本。
+	algorithms      map[string]bool // 接受的消息认证码算法，为 nil 时接受所有已注册的算法。
+	scopes          map[string]bool // 接受的 Scope ，为 nil 时接受任意 Scope 。
+	maxBodySize     int64
+	nonceStore      NonceStore
+	debugHook       DebugHookFunc
+	signOption      SignOption
+	trustedProxies  trustedProxies
+	derivedKeys     *derivedKeyCache
 }
 
 // 初始化解签对象
 func NewSigAuthResolver(authScheme string, secretFinder SecretFinderFunc, timeChecker TimeCheckerFunc) *sigAuthResolver {
-	if secretFinder == nil {
-		panic("secretFinder must be provided")
+	if timeChecker == nil {
+		panic("timeChecker must be provided")
 	}
 
+	return NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		AuthScheme:   authScheme,
+		SecretFinder: secretFinder,
+		TimeChecker:  timeChecker,
+	})
+}
+
+// NewSigAuthResolverWithOption 根据 [SigAuthHandlerOption] 初始化解签对象，未给定的可选项使用其默认值。
+// SecretFinder 和 PublicKeyFinder 至少给定一个。
+func NewSigAuthResolverWithOption(op SigAuthHandlerOption) *sigAuthResolver {
+	secretProvider := op.SecretProvider
+	if secretProvider == nil && op.SecretFinder != nil {
+		secretProvider = op.SecretFinder
+	}
+
+	if secretProvider == nil && op.PublicKeyFinder == nil {
+		panic("secretFinder, secretProvider or publicKeyFinder must be provided")
+	}
+
+	timeChecker := op.ExpiryTimeChecker
 	if timeChecker == nil {
-		panic("timeChecker must be provided")
+		if op.TimeChecker != nil {
+			timeChecker = op.TimeChecker.WithExpiry()
+		} else {
+			timeChecker = DefaultExpiryTimeChecker
+		}
+	}
+
+	var versions map[int]bool
+	if len(op.Versions) > 0 {
+		versions = make(map[int]bool, len(op.Versions))
+		for _, v := range op.Versions {
+			versions[v] = true
+		}
+	}
+
+	var algorithms map[string]bool
+	if len(op.Algorithms) > 0 {
+		algorithms = make(map[string]bool, len(op.Algorithms))
+		for _, name := range op.Algorithms {
+			algorithms[name] = true
+		}
+	}
+
+	var scopes map[string]bool
+	if len(op.Scopes) > 0 {
+		scopes = make(map[string]bool, len(op.Scopes))
+		for _, scope := range op.Scopes {
+			scopes[scope] = true
+		}
 	}
 
 	return &sigAuthResolver{
-		authScheme:   authScheme,
-		secretFinder: secretFinder,
-		timeChecker:  timeChecker,
+		authScheme:      op.AuthScheme,
+		secretProvider:  secretProvider,
+		publicKeyFinder: op.PublicKeyFinder,
+		timeChecker:     timeChecker,
+		maxLifetime:     op.MaxLifetime,
+		versions:        versions,
+		algorithms:      algorithms,
+		scopes:          scopes,
+		maxBodySize:     op.MaxBodySize,
+		nonceStore:      op.NonceStore,
+		debugHook:       op.DebugHook,
+		signOption:      op.SignOption,
+		trustedProxies:  parseTrustedProxies(op.TrustedProxies),
+		derivedKeys:     newDerivedKeyCache(),
 	}
 }
 
-func (x sigAuthResolver) VerifySignature(r *http.Request) {
+// Verify 校验请求的签名。校验通过时返回解析得到的 Authorization ；否则返回错误，可通过 [errors.Is] 判断原因：
+//   - [ErrMissingAuthorization] 没有 Authorization 头，也没有 ~auth 参数。
+//   - [ErrInvalidAuthorization] Authorization 格式错误。
+//   - [ErrUnsupportedVersion] 签名算法版本没有注册，或不在 [SigAuthHandlerOption.Versions] 之中。
+//   - [ErrUnsupportedAlgorithm] 消息认证码算法没有注册，或不在 [SigAuthHandlerOption.Algorithms] 之中。
+//   - [ErrUnknownKey] Key 没有绑定 secret 或公钥。
+//   - [ErrKeyDisabled] Key 绑定的 secret 已被禁用。
+//   - [ErrSecretLookup] [SecretProvider] 查找 secret 时出错。
+//   - [ErrTimestampOutOfRange] 时间戳校验不通过，或签名已过期、有效期超过 [SigAuthHandlerOption.MaxLifetime] ，
+//     或没有给定 MaxLifetime 时签名带有 Expires 。
+//   - [ErrSignatureMismatch] 签名不匹配。
+//   - [ErrMissingNonce] 配置了 NonceStore ，但没有给出 Nonce 。
+//   - [ErrReplayedNonce] Nonce 已被使用过。
+//   - [ErrScopeNotAllowed] Scope 不在 [SigAuthHandlerOption.Scopes] 之中。
+//
+// 给定了 [SigAuthHandlerOption.MaxLifetime] 时，带 Expires 的签名（如 [PresignURL] 生成的链接）在有效期内均可通过，
+// 不受时间戳误差范围的限制。
+//
+// 若签名计算本身失败（如缺少 Content-Type ），返回 [*SignError] ，可通过 [errors.As] 获取。
+// 校验后 [http.Request.Body] 被替换为可重读的 [bytes.Buffer] ，后续处理可正常读取。
+// 若使用 X-Content-SHA256 头代替 body 签名，则 body 不被读取，后续读取 body 时可能返回
+// [ErrBodyDigestMismatch] 或 [ErrBodyTooLarge] ，处理方需检查读取 body 时的错误。
+//
+// 返回的错误不包含期望的签名等敏感信息，这些信息仅通过 [SigAuthHandlerOption.DebugHook] 给出。
+// 除 [ErrSecretLookup] 及不属于上述任何一种的错误（如 NonceStore 返回的“nonce store: ...”）之外，
+// 返回的错误可以直接展示给客户端；这两类错误可能包含存储等内部信息，对应 500 状态码，见 [ErrorStatusCode] 。
+func (x sigAuthResolver) Verify(r *http.Request) (*Authorization, error) {
+	auth, _, err := x.verifyWithSecret(r)
+	return auth, err
+}
+
+// VerifyWithSecret 同 [sigAuthResolver.Verify] ，同时返回对称签名所用的 [Secret] 的元数据，
+// 如 Owner 、 Scopes 及匹配的版本的 Generation ，与 [SecretFromContext] 返回的相同，其 Value 、 Versions 字段总是为空。
+// 使用公钥验签或验签失败时， Secret 为零值。
+func (x sigAuthResolver) VerifyWithSecret(r *http.Request) (*Authorization, Secret, error) {
+	auth, secret, err := x.verifyWithSecret(r)
+	return auth, secret.metadata(), err
+}
+
+// 同 Verify ，同时返回对称签名所用的 Secret （非对称签名时为零值）。
+func (x sigAuthResolver) verifyWithSecret(r *http.Request) (*Authorization, Secret, error) {
+	diag := VerifyDiagnostic{}
+	var secret Secret
+	auth, err := x.verify(r, &diag, &secret)
+	if err != nil {
+		if x.debugHook != nil {
+			diag.Err = err
+			x.debugHook(r, diag)
+		}
+		return nil, Secret{}, err
+	}
+	return auth, secret, nil
+}
+
+// 执行验签，过程中得到的诊断信息写入 diag ，找到的 secret 写入 secret 。
+func (x sigAuthResolver) verify(r *http.Request, diag *VerifyDiagnostic, secret *Secret) (*Authorization, error) {
 	auth, err := ParseAuthorizationHeader(r, x.authScheme)
 	if err != nil {
-		panic("invalid Authorization")
+		if errors.Is(err, ErrMissingAuthorization) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAuthorization, err)
+	}
+	diag.Authorization = &auth
+
+	if x.versions != nil && !x.versions[auth.Version] {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, auth.Version)
 	}
 
-	// 签名算法目前就一个版本，不允许出现其他值。
-	if auth.Version != DefaultSignVersion {
-		panic("unsupported signature version")
+	alg, ok := LookupSignAlgorithm(auth.Version)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, auth.Version)
+	}
+
+	if x.nonceStore != nil {
+		if auth.Nonce == "" {
+			return nil, ErrMissingNonce
+		}
+
+		// 带 Expires 的签名在有效期内均可通过，其 nonce 需要保留到 Expires 。
+		if _, ok := x.nonceStore.(ExpiringNonceStore); !ok && auth.Expires != 0 {
+			return nil, fmt.Errorf("%w: the nonce store cannot retain nonces until Expires", ErrTimestampOutOfRange)
+		}
+	}
+
+	// 非对称签名使用公钥验签，其余使用 secret 。
+	asymAlg, isAsym := alg.(AsymmetricSignAlgorithm)
+	var publicKey crypto.PublicKey
+	if isAsym {
+		if x.publicKeyFinder == nil {
+			return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, auth.Version)
+		}
+
+		publicKey = x.publicKeyFinder(auth.Key)
+		if publicKey == nil {
+			return nil, ErrUnknownKey
+		}
+	} else {
+		if x.secretProvider == nil {
+			return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, auth.Version)
+		}
+
+		algorithm := auth.Algorithm
+		if algorithm == "" {
+			algorithm = DefaultMacAlgorithm
+		}
+		if x.algorithms != nil && !x.algorithms[algorithm] {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+		}
+
+		s, err := x.secretProvider.Secret(r.Context(), auth.Key)
+		switch {
+		case errors.Is(err, ErrUnknownKey):
+			return nil, err
+		case err != nil:
+			return nil, fmt.Errorf("%w: %w", ErrSecretLookup, err)
+		case s.empty():
+			return nil, ErrUnknownKey
+		case s.Disabled:
+			return nil, ErrKeyDisabled
+		}
+		*secret = s
 	}
 
-	secret := x.secretFinder(auth.Key)
-	if secret == "" {
-		panic("unknown key")
+	if x.maxBodySize > 0 && r.Body != nil {
+		r.Body = newLimitedBody(r.Body, x.maxBodySize)
 	}
 
-	// 签名
-	signResult := Sign(r, true, secret, auth.Timestamp)
+	// 构建待签名串。
+	data, typ, signErr := alg.BuildDataToSign(r, true, auth, x.requestSignOption(r))
 
 	// 时间戳校验。
-	timeCheckErr := x.timeChecker(auth.Timestamp)
+	timeCheckErr := x.checkTime(alg, auth)
 	if timeCheckErr != nil {
-		panic("timestamp error")
+		return nil, fmt.Errorf("%w: %w", ErrTimestampOutOfRange, timeCheckErr)
 	}
 
-	switch signResult.Type {
-	case SignResultType_MissingContentType:
-		panic("missing Content-Type")
+	if typ != SignResultType_OK {
+		return nil, &SignError{Type: typ, Cause: signErr}
+	}
+	diag.DataToSign = string(data)
+
+	if isAsym {
+		if err := asymAlg.VerifyWithKey(publicKey, data, auth.Sign, auth); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+		}
+	} else {
+		// 依次尝试候选的 secret ，使用恒定时间的比较，避免通过响应时间推测签名。
+		// 匹配后不提前结束，使耗时与匹配的是哪个版本无关。
+		candidates := secret.candidates(time.Now())
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%w: no active secret", ErrSignatureMismatch)
+		}
+
+		matched := -1
+		for i, c := range candidates {
+			sign, err := x.mac(alg, c.Value, data, auth)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrUnsupportedAlgorithm, err)
+			}
+			if i == 0 {
+				diag.ExpectedSign = sign
+			}
+
+			if hmac.Equal([]byte(sign), []byte(auth.Sign)) && matched < 0 {
+				matched = i
+			}
+		}
+
+		if matched < 0 {
+			return nil, ErrSignatureMismatch
+		}
+		secret.Generation = candidates[matched].Generation
+	}
+
+	// 签名通过后才校验 Scope ，使未通过验签的请求不能探测接受的 Scope 。
+	if x.scopes != nil && auth.Scope != "" && !x.scopes[auth.Scope] {
+		return nil, fmt.Errorf("%w: %s", ErrScopeNotAllowed, auth.Scope)
+	}
+
+	// 签名通过后才记录 nonce ，避免伪造的请求占用 nonce 。
+	if x.nonceStore != nil {
+		var ok bool
+		if store, isExpiring := x.nonceStore.(ExpiringNonceStore); isExpiring && auth.Expires != 0 {
+			ok, err = store.UseUntil(auth.Key, auth.Nonce, time.Unix(auth.Expires, 0))
+		} else {
+			ok, err = x.nonceStore.Use(auth.Key, auth.Nonce)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("nonce store: %w", err)
+		}
+		if !ok {
+			return nil, ErrReplayedNonce
+		}
+	}
+
+	return &auth, nil
+}
+
+// 使用 secret 计算签名。对于 [KeyDerivingSignAlgorithm] ，派生的签名密钥被缓存。
+func (x sigAuthResolver) mac(alg SignAlgorithm, secret string, data []byte, auth Authorization) (string, error) {
+	derivingAlg, ok := alg.(KeyDerivingSignAlgorithm)
+	if !ok {
+		return alg.Mac(secret, data, auth)
+	}
+
+	key, err := x.derivedKeys.deriveKey(derivingAlg, secret, auth)
+	if err != nil {
+		return "", err
+	}
+	return derivingAlg.MacWithKey(key, data, auth)
+}
+
+// 校验时间戳和 Expires ，包括有效期不超过 maxLifetime 。
+func (x sigAuthResolver) checkTime(alg SignAlgorithm, auth Authorization) error {
+	if auth.Expires != 0 {
+		if x.maxLifetime == 0 {
+			return errors.New("signatures with Expires are not accepted")
+		}
+
+		lifetime := auth.Expires - auth.Timestamp
+		if lifetime <= 0 {
+			return fmt.Errorf("expires %d should be later than the timestamp %d", auth.Expires, auth.Timestamp)
+		}
 
-	case SignResultType_UnsupportedContentType:
-		panic("unsupported Content-Type")
+		if x.maxLifetime > 0 && lifetime > x.maxLifetime {
+			return fmt.Errorf("the lifetime of the signature should be at most %ds, got %ds", x.maxLifetime, lifetime)
+		}
 
-	case SignResultType_InvalidRequestBody:
-		panic("invalid request body")
+		// 派生的签名密钥只在当天有效，其签名的有效期也不能超过当天。
+		if _, ok := alg.(KeyDerivingSignAlgorithm); ok {
+			if end := derivationDayEnd(auth.Timestamp); auth.Expires > end {
+				return fmt.Errorf("expires %d should not be later than the end of the day of the timestamp, %d", auth.Expires, end)
+			}
+		}
 	}
 
-	if signResult.Sign != auth.Sign {
-		panic(fmt.Sprintf("signature mismatch, want %s, got %s", signResult.Sign, auth.Sign))
+	return x.timeChecker(auth.Timestamp, auth.Expires)
+}
+
+// 返回用于给定请求的 SignOption ：请求直接来自可信代理时，使用转发头给出的 scheme 和 host 。
+func (x sigAuthResolver) requestSignOption(r *http.Request) SignOption {
+	opt := x.signOption
+	if (opt.SignScheme || opt.SignHost) && len(x.trustedProxies) > 0 && x.trustedProxies.contains(r.RemoteAddr) {
+		opt.scheme, opt.host = forwardedSchemeAndHost(r)
+	}
+	return opt
+}
+
+// VerifySignature 校验请求的签名，校验不通过时直接 panic ，panic 的值是描述错误的字符串。
+//
+// Deprecated: 仅为兼容保留，请使用 [sigAuthResolver.Verify] 。
+func (x sigAuthResolver) VerifySignature(r *http.Request) {
+	if _, err := x.Verify(r); err != nil {
+		panic(legacyErrorMessage(err))
+	}
+}
+
+// 将 [sigAuthResolver.Verify] 返回的错误转换为 VerifySignature 原先 panic 的字符串。
+func legacyErrorMessage(err error) string {
+	var signErr *SignError
+	switch {
+	case errors.Is(err, ErrMissingAuthorization), errors.Is(err, ErrInvalidAuthorization):
+		return ErrInvalidAuthorization.Error()
+
+	case errors.Is(err, ErrUnsupportedVersion):
+		return ErrUnsupportedVersion.Error()
+
+	case errors.Is(err, ErrUnsupportedAlgorithm):
+		return ErrUnsupportedAlgorithm.Error()
+
+	case errors.Is(err, ErrTimestampOutOfRange):
+		return ErrTimestampOutOfRange.Error()
+
+	case errors.As(err, &signErr):
+		return signErr.Type.String()
+
+	default:
+		return err.Error()
 	}
 }
@@ -0,0 +1,286 @@
+package sigauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 测试 Verify 返回的错误类型
+func TestSigAuthResolver_Verify(t *testing.T) {
+	resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+
+	t.Run("MissingAuthorization", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrMissingAuthorization)
+	})
+
+	t.Run("InvalidAuthorization", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key", DefaultAuthScheme))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrInvalidAuthorization)
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key=%s, Sign=s, Timestamp=1, Version=9", DefaultAuthScheme, _key))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key=unknown, Sign=s, Timestamp=1", DefaultAuthScheme))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("TimestampOutOfRange", func(t *testing.T) {
+		resolver := NewSigAuthResolver("", finderForTest, DefaultTimeChecker)
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrTimestampOutOfRange)
+	})
+
+	t.Run("SignError", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeJson, "")
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key=%s, Sign=s, Timestamp=1", DefaultAuthScheme, _key))
+		_, err := resolver.Verify(r)
+
+		var signErr *SignError
+		require.True(t, errors.As(err, &signErr))
+		assert.Equal(t, SignResultType_InvalidRequestBody, signErr.Type)
+	})
+
+	t.Run("SignatureMismatch", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		r.Header.Set(HttpHeaderAuthorization, fmt.Sprintf("%s Key=%s, Sign=bad, Timestamp=1", DefaultAuthScheme, _key))
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		r := newRequest("", "/p?a=1", _requestTypeForm, "x=1")
+		AppendSign(r, _key, _secret, "", _timestamp)
+		auth, err := resolver.Verify(r)
+		require.NoError(t, err)
+		assert.Equal(t, _key, auth.Key)
+		assert.Equal(t, int64(_timestamp), auth.Timestamp)
+		assert.Equal(t, DefaultSignVersion, auth.Version)
+	})
+}
+
+// 测试 VerifySignature 仍以原先的字符串 panic
+func TestSigAuthResolver_VerifySignature(t *testing.T) {
+	resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+	r := newRequest("", "/", _requestTypeGet, "")
+	assert.PanicsWithValue(t, "invalid Authorization", func() {
+		resolver.VerifySignature(r)
+	})
+}
+
+// 测试诊断信息只通过 DebugHook 给出
+func TestSigAuthResolver_debugHook(t *testing.T) {
+	var diag VerifyDiagnostic
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		DebugHook: func(r *http.Request, d VerifyDiagnostic) {
+			diag = d
+		},
+	})
+
+	auth := BuildAuthorizationHeader(Authorization{
+		Key:       _key,
+		Sign:      "bad",
+		Timestamp: _timestamp,
+	})
+	r := newRequest("", "/?Plus&x=1", _requestTypeGet, "")
+	r.Header.Set(HttpHeaderAuthorization, auth)
+
+	_, err := resolver.Verify(r)
+	require.ErrorIs(t, err, ErrSignatureMismatch)
+	assert.NotContains(t, err.Error(), "b7843d37ae086202d5f3e44b49b1b20ebcaf9a668347e839602a0d41156bb68d")
+
+	assert.Equal(t, err, diag.Err)
+	assert.Equal(t, "b7843d37ae086202d5f3e44b49b1b20ebcaf9a668347e839602a0d41156bb68d", diag.ExpectedSign)
+	assert.Equal(t, "1661934251\nGET\n/\nPlus1\nEND", diag.DataToSign)
+	assert.Equal(t, _key, diag.Authorization.Key)
+}
+
+// 测试 v2 签名覆盖指定的 HTTP 头
+func TestSigAuthResolver_signedHeaders(t *testing.T) {
+	resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+
+	newSignedRequest := func() *http.Request {
+		r := newRequest("", "/p", _requestTypeGet, "")
+		r.Header.Set("X-Tenant-Id", "t1")
+		res := AppendSignWith(r, _secret, Authorization{
+			Key:           _key,
+			Timestamp:     _timestamp,
+			Version:       SignVersion2,
+			SignedHeaders: []string{"host", "x-tenant-id"},
+		})
+		require.Equal(t, SignResultType_OK, res.Type)
+		return r
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r := newSignedRequest()
+		auth, err := resolver.Verify(r)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"host", "x-tenant-id"}, auth.SignedHeaders)
+	})
+
+	t.Run("TamperedHeader", func(t *testing.T) {
+		r := newSignedRequest()
+		r.Header.Set("X-Tenant-Id", "t2")
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+
+	t.Run("V1", func(t *testing.T) {
+		// v1 不签名 HTTP 头，给出 SignedHeaders 时签名和验签均失败，以免头被篡改而不被发现。
+		r := newRequest("", "/p", _requestTypeGet, "")
+		r.Header.Set("X-Tenant-Id", "t1")
+		res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, SignedHeaders: []string{"x-tenant-id"}})
+		assert.Equal(t, SignResultType_InvalidSignedHeaders, res.Type)
+
+		r = newRequest("", "/p", _requestTypeGet, "")
+		require.Equal(t, SignResultType_OK, AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp}).Type)
+		r.Header.Set(HttpHeaderAuthorization, r.Header.Get(HttpHeaderAuthorization)+", SignedHeaders=x-tenant-id")
+		r.Header.Set("X-Tenant-Id", "EVIL")
+		_, err := resolver.Verify(r)
+
+		var signErr *SignError
+		require.ErrorAs(t, err, &signErr)
+		assert.Equal(t, SignResultType_InvalidSignedHeaders, signErr.Type)
+		assert.Equal(t, http.StatusBadRequest, ErrorStatusCode(err))
+	})
+
+	t.Run("TamperedHost", func(t *testing.T) {
+		r := newSignedRequest()
+		r.Host = "evil.org"
+		_, err := resolver.Verify(r)
+		require.ErrorIs(t, err, ErrSignatureMismatch)
+	})
+}
+
+func TestSigAuthResolver_expires(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		MaxLifetime:  3600,
+	})
+
+	verify := func(timestamp, expires int64) error {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: timestamp, Expires: expires})
+		_, err := resolver.Verify(r)
+		return err
+	}
+
+	now := time.Now().Unix()
+	assert.NoError(t, verify(now, 0))
+	assert.NoError(t, verify(now-1800, now+60))
+
+	// 短有效期的签名过期后不能再使用。
+	assert.ErrorIs(t, verify(now-120, now-60), ErrTimestampOutOfRange)
+
+	// 超过最长有效期。
+	assert.ErrorIs(t, verify(now, now+3601), ErrTimestampOutOfRange)
+
+	// Expires 需晚于 Timestamp 。
+	assert.ErrorIs(t, verify(now, now), ErrTimestampOutOfRange)
+
+	// 篡改 Expires 会导致签名不匹配。
+	r := newRequest("", "/", _requestTypeGet, "")
+	AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: now, Expires: now + 60})
+	r.Header.Set(HttpHeaderAuthorization, strings.Replace(
+		r.Header.Get(HttpHeaderAuthorization),
+		fmt.Sprintf("Expires=%d", now+60),
+		fmt.Sprintf("Expires=%d", now+600), 1))
+	_, err := resolver.Verify(r)
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+
+	t.Run("NotAccepted", func(t *testing.T) {
+		// 没有给定 MaxLifetime 时不接受带 Expires 的签名，即使其时间戳在误差范围内。
+		resolver := NewSigAuthResolver("", finderForTest, DefaultTimeChecker)
+
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: now, Expires: now + 7*24*3600})
+		_, err := resolver.Verify(r)
+		assert.ErrorIs(t, err, ErrTimestampOutOfRange)
+
+		r = newRequest("", "/", _requestTypeGet, "")
+		AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: now})
+		_, err = resolver.Verify(r)
+		assert.NoError(t, err)
+	})
+}
+
+// 测试修改 Version 后签名不能通过：各版本的待签名串不会相同
+func TestSigAuthResolver_versionChanged(t *testing.T) {
+	resolver := NewSigAuthResolver("", finderForTest, NoTimeChecker)
+
+	changeVersion := func(r *http.Request, from, to int) {
+		r.Header.Set(HttpHeaderAuthorization, strings.Replace(
+			r.Header.Get(HttpHeaderAuthorization),
+			fmt.Sprintf("Version=%d", from),
+			fmt.Sprintf("Version=%d", to), 1))
+	}
+
+	for _, c := range []struct{ from, to int }{
+		{SignVersion2, DefaultSignVersion},
+		{SignVersion5, DefaultSignVersion},
+		{SignVersion5, SignVersion2},
+		{SignVersion2, SignVersion5},
+		{SignVersionDerived, SignVersion5},
+	} {
+		auth := Authorization{Key: _key, Timestamp: _timestamp, Version: c.from}
+		if c.from == SignVersionDerived {
+			auth.Scope = "s"
+		}
+
+		r := newRequest("", "/p", _requestTypeGet, "")
+		res := AppendSignWith(r, _secret, auth)
+		require.Equal(t, SignResultType_OK, res.Type)
+
+		_, err := resolver.Verify(r)
+		require.NoError(t, err)
+
+		changeVersion(r, c.from, c.to)
+		_, err = resolver.Verify(r)
+		if auth.Scope == "" {
+			assert.ErrorIs(t, err, ErrSignatureMismatch, "v%d as v%d", c.from, c.to)
+		} else {
+			// v5 不签名 Scope ，不接受带 Scope 的请求；去掉 Scope 后同样不能作为 v5 的签名使用。
+			var signErr *SignError
+			require.ErrorAs(t, err, &signErr)
+			assert.Equal(t, SignResultType_InvalidScope, signErr.Type)
+
+			r.Header.Set(HttpHeaderAuthorization, strings.Replace(r.Header.Get(HttpHeaderAuthorization), ", Scope=s", "", 1))
+			_, err = resolver.Verify(r)
+			assert.ErrorIs(t, err, ErrSignatureMismatch, "v%d as v%d", c.from, c.to)
+		}
+	}
+
+	// v5 的签名不能降级为 v1 ，利用 v1 QUERY 部分的歧义用于其他请求。
+	r := newRequest("", "/p?a=1&b=2", _requestTypeGet, "")
+	res := AppendSignWith(r, _secret, Authorization{Key: _key, Timestamp: _timestamp, Version: SignVersion5})
+	require.Equal(t, SignResultType_OK, res.Type)
+
+	forged := newRequest("", "/p?z=a%3D1%26b%3D2%0A", _requestTypeGet, "")
+	forged.Header.Set(HttpHeaderAuthorization, r.Header.Get(HttpHeaderAuthorization))
+	changeVersion(forged, SignVersion5, DefaultSignVersion)
+	_, err := resolver.Verify(forged)
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+}
@@ -0,0 +1,89 @@
+package sigauth
+
+import (
+	"context"
+	"time"
+)
+
+/* 当前文件提供 secret 的查找方式。 */
+
+// Secret 是绑定到 accessKey 的 secret 及其元数据，由 [SecretProvider] 返回。
+type Secret struct {
+	Value    string   // 计算签名所用的 secret ，使用 UTF-8 字符集。给定 Versions 时被忽略。
+	Owner    string   // 可选，secret 的所有者，如用户或服务的名称。
+	Scopes   []string // 可选，此 secret 被授予的权限范围，由业务自行解释。
+	Disabled bool     // 是否已被禁用。禁用的 secret 验签失败，返回 [ErrKeyDisabled] 。
+
+	// 可选，用于轮换 secret ：多个候选的 secret ，如当前的和之前的，验签时依次尝试，任一匹配即通过。
+	// 只有在有效期内的版本参与验签。 Value 和 Versions 均为空表示 accessKey 没有绑定 secret 。
+	Versions []SecretVersion
+
+	// 验签通过时匹配的版本的 [SecretVersion.Generation] ，只在 [SecretFromContext] 或
+	// [sigAuthResolver.VerifyWithSecret] 返回的 Secret 中有意义。
+	// 没有给出 Versions 时为 0 。可用于统计旧的 secret 何时不再被使用。
+	Generation int
+}
+
+// SecretVersion 是 secret 的一个版本。
+type SecretVersion struct {
+	Generation int       // 版本号，用于识别匹配的版本，如递增的序号。
+	Value      string    // 计算签名所用的 secret ，使用 UTF-8 字符集。
+	NotBefore  time.Time // 可选，在此时间之前不可用。零值表示不限制。
+	NotAfter   time.Time // 可选，在此时间之后不可用。零值表示不限制。
+}
+
+// 判断 secret 是否没有任何版本。
+func (x Secret) empty() bool {
+	return x.Value == "" && len(x.Versions) == 0
+}
+
+// 返回去掉 Value 、 Versions 后的元数据，用于交给后续的处理方。
+func (x Secret) metadata() Secret {
+	x.Value = ""
+	x.Versions = nil
+	return x
+}
+
+// 返回在 now 时刻可用的候选 secret ，保持 Versions 的顺序。没有给出 Versions 时，为 Value 本身。
+func (x Secret) candidates(now time.Time) []SecretVersion {
+	if len(x.Versions) == 0 {
+		return []SecretVersion{{Value: x.Value}}
+	}
+
+	res := make([]SecretVersion, 0, len(x.Versions))
+	for _, v := range x.Versions {
+		if v.Value == "" {
+			continue
+		}
+		if !v.NotBefore.IsZero() && now.Before(v.NotBefore) {
+			continue
+		}
+		if !v.NotAfter.IsZero() && now.After(v.NotAfter) {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+// SecretProvider 用于获取绑定到指定 accessKey 的 secret 及其元数据。实现需是并发安全的。
+//   - 若 accessKey 没有绑定，返回 Value 为空的 Secret 和 nil ，或返回 [ErrUnknownKey] 。
+//   - 若获取过程出错（如数据库不可用），返回 error ，验签失败并返回包装了此错误的 [ErrSecretLookup] 。
+//
+// ctx 来自请求的 [http.Request.Context] ，可用于取消查询。
+type SecretProvider interface {
+	Secret(ctx context.Context, accessKey string) (Secret, error)
+}
+
+// SecretProviderFunc 是函数形式的 [SecretProvider] 。
+type SecretProviderFunc func(ctx context.Context, accessKey string) (Secret, error)
+
+// Secret 实现 [SecretProvider] 。
+func (f SecretProviderFunc) Secret(ctx context.Context, accessKey string) (Secret, error) {
+	return f(ctx, accessKey)
+}
+
+// Secret 实现 [SecretProvider] ，返回的 Secret 只有 Value 字段。 f 的 panic 不被处理，同 [SecretFinderFunc] 的约定。
+func (f SecretFinderFunc) Secret(ctx context.Context, accessKey string) (Secret, error) {
+	return Secret{Value: f(accessKey)}, nil
+}
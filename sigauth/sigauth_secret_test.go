@@ -0,0 +1,221 @@
+package sigauth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCtxKey struct{}
+
+var errStoreDown = errors.New("store down")
+
+func providerForTest(ctx context.Context, accessKey string) (Secret, error) {
+	switch accessKey {
+	case _key:
+		return Secret{Value: _secret, Owner: "owner", Scopes: []string{"read"}}, nil
+	case "disabled":
+		return Secret{Value: _secret, Disabled: true}, nil
+	case "down":
+		return Secret{}, errStoreDown
+	case "ctx":
+		if ctx.Value(testCtxKey{}) == nil {
+			return Secret{}, errors.New("missing context")
+		}
+		return Secret{Value: _secret}, nil
+	default:
+		return Secret{}, ErrUnknownKey
+	}
+}
+
+func TestSecretFinderFunc_Secret(t *testing.T) {
+	var provider SecretProvider = SecretFinderFunc(finderForTest)
+
+	s, err := provider.Secret(context.Background(), _key)
+	require.NoError(t, err)
+	assert.Equal(t, Secret{Value: _secret}, s)
+
+	s, err = provider.Secret(context.Background(), "none")
+	require.NoError(t, err)
+	assert.Empty(t, s.Value)
+}
+
+func TestSigAuthResolver_secretProvider(t *testing.T) {
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretFinder:   func(string) string { panic("should not be called") },
+		SecretProvider: SecretProviderFunc(providerForTest),
+		TimeChecker:    NoTimeChecker,
+	})
+
+	verify := func(key string, ctx context.Context) error {
+		r := newRequest("", "/", _requestTypeGet, "")
+		AppendSign(r, key, _secret, "", _timestamp)
+		_, err := resolver.Verify(r.WithContext(ctx))
+		return err
+	}
+
+	assert.NoError(t, verify(_key, context.Background()))
+	assert.ErrorIs(t, verify("none", context.Background()), ErrUnknownKey)
+	assert.ErrorIs(t, verify("disabled", context.Background()), ErrKeyDisabled)
+
+	err := verify("down", context.Background())
+	assert.ErrorIs(t, err, ErrSecretLookup)
+	assert.ErrorIs(t, err, errStoreDown)
+
+	assert.ErrorIs(t, verify("ctx", context.Background()), ErrSecretLookup)
+	assert.NoError(t, verify("ctx", context.WithValue(context.Background(), testCtxKey{}, 1)))
+}
+
+func TestMiddleware_secretProvider(t *testing.T) {
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretProvider: SecretProviderFunc(providerForTest),
+		TimeChecker:    NoTimeChecker,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := SecretFromContext(r.Context())
+		require.True(t, ok)
+		assert.Empty(t, secret.Value)
+		assert.Equal(t, []string{"read"}, secret.Scopes)
+		w.Write([]byte(secret.Owner))
+	})))
+	defer s.Close()
+
+	do := func(key string) (int, string) {
+		r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		AppendSign(r, key, _secret, "", _timestamp)
+		res, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	code, body := do(_key)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "owner", body)
+
+	code, _ = do("disabled")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// 内部错误不展示给客户端。
+	code, body = do("down")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.NotContains(t, body, errStoreDown.Error())
+}
+
+func TestSecret_candidates(t *testing.T) {
+	now := time.Unix(_timestamp, 0)
+
+	s := Secret{Value: "v"}
+	assert.Equal(t, []SecretVersion{{Value: "v"}}, s.candidates(now))
+
+	s = Secret{
+		Value: "ignored",
+		Versions: []SecretVersion{
+			{Generation: 3, Value: "future", NotBefore: now.Add(time.Hour)},
+			{Generation: 2, Value: "current", NotBefore: now.Add(-time.Hour)},
+			{Generation: 1, Value: "previous", NotAfter: now.Add(time.Hour)},
+			{Generation: 0, Value: "expired", NotAfter: now.Add(-time.Second)},
+			{Generation: 4},
+		},
+	}
+	var generations []int
+	for _, c := range s.candidates(now) {
+		generations = append(generations, c.Generation)
+	}
+	assert.Equal(t, []int{2, 1}, generations)
+}
+
+func TestMiddleware_secretRotation(t *testing.T) {
+	now := time.Now()
+	versions := []SecretVersion{
+		{Generation: 2, Value: "new"},
+		{Generation: 1, Value: "old", NotAfter: now.Add(time.Hour)},
+		{Generation: 0, Value: "retired", NotAfter: now.Add(-time.Hour)},
+	}
+
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretProvider: SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+			switch accessKey {
+			case _key:
+				return Secret{Versions: versions}, nil
+			case "allRetired":
+				return Secret{Versions: versions[2:]}, nil
+			default:
+				return Secret{}, nil
+			}
+		}),
+		TimeChecker: NoTimeChecker,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, _ := SecretFromContext(r.Context())
+		assert.Nil(t, secret.Versions)
+		w.Write([]byte(strconv.Itoa(secret.Generation)))
+	})))
+	defer s.Close()
+
+	do := func(key, secret string) (int, string) {
+		r, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		AppendSign(r, key, secret, "", _timestamp)
+		res, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	code, body := do(_key, "new")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "2", body)
+
+	code, body = do(_key, "old")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", body)
+
+	code, _ = do(_key, "retired")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = do("allRetired", "retired")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = do("none", "new")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestSigAuthResolver_VerifyWithSecret(t *testing.T) {
+	now := time.Now()
+	resolver := NewSigAuthResolverWithOption(SigAuthHandlerOption{
+		SecretProvider: SecretProviderFunc(func(ctx context.Context, accessKey string) (Secret, error) {
+			return Secret{Owner: "owner", Versions: []SecretVersion{
+				{Generation: 2, Value: "new"},
+				{Generation: 1, Value: "old", NotAfter: now.Add(time.Hour)},
+			}}, nil
+		}),
+		TimeChecker: NoTimeChecker,
+	})
+
+	verify := func(secret string) (Secret, error) {
+		r, _ := http.NewRequest(http.MethodGet, "http://temp.org/", nil)
+		AppendSign(r, _key, secret, "", _timestamp)
+		_, s, err := resolver.VerifyWithSecret(r)
+		return s, err
+	}
+
+	s, err := verify("old")
+	require.NoError(t, err)
+	assert.Equal(t, Secret{Owner: "owner", Generation: 1}, s)
+
+	s, err = verify("new")
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Generation)
+
+	s, err = verify("bad")
+	assert.ErrorIs(t, err, ErrSignatureMismatch)
+	assert.Equal(t, Secret{}, s)
+}
@@ -5,8 +5,10 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"sort"
@@ -22,11 +24,23 @@ type Authorization struct {
 	Key        string // 请求方的标识。
 	Sign       string // 签名。
 	Timestamp  int64  // 生成签名时的 UNIX 时间戳，单位是秒。
+	Expires    int64  // 可选，签名过期时的 UNIX 时间戳，单位是秒，需晚于 Timestamp 。为 0 时没有此字段。
+	Nonce      string // 可选，随机串，用于防止请求被重放。不能包含空格和逗号。
 	Version    int    // 算法版本。在 Authorization 头未给出时，默认为 [DefaultSignVersion] 。
+	Algorithm  string // 消息认证码算法，见 [RegisterMacAlgorithm] 。在 Authorization 头未给出时，默认为 [DefaultMacAlgorithm] 。
+	Scope      string // 签名密钥的使用范围，如服务、区域或租户，用于 [SignVersionDerived] 。为空时没有此字段。
+
+	// 参与签名的 HTTP 头的名称， [SignVersion2] 起使用， v1 给出时签名失败。名称不区分大小写，在头中以分号分隔。
+	SignedHeaders []string
 }
 
 // BuildAuthorizationHeader 返回用于 HTTP 的 Authorization 头的值。
 //   - 若 [Authorization.Version] 为 0 ，则 Version 部分被省略。
+//   - 若 [Authorization.Expires] 为 0 ，则 Expires 部分被省略。
+//   - 若 [Authorization.Nonce] 为空，则 Nonce 部分被省略。
+//   - 若 [Authorization.Algorithm] 为空，则 Algorithm 部分被省略。
+//   - 若 [Authorization.SignedHeaders] 为空，则 SignedHeaders 部分被省略。
+//   - 若 [Authorization.Scope] 为空，则 Scope 部分被省略。
 //   - 若 [Authorization.AuthScheme] 为空，则使用默认值 [DefaultAuthScheme] 。
 func BuildAuthorizationHeader(auth Authorization) string {
 	b := new(strings.Builder)
@@ -45,11 +59,36 @@ func BuildAuthorizationHeader(auth Authorization) string {
 	b.WriteString(", Timestamp=")
 	b.WriteString(strconv.FormatInt(auth.Timestamp, 10))
 
+	if auth.Expires != 0 {
+		b.WriteString(", Expires=")
+		b.WriteString(strconv.FormatInt(auth.Expires, 10))
+	}
+
+	if auth.Nonce != "" {
+		b.WriteString(", Nonce=")
+		b.WriteString(auth.Nonce)
+	}
+
 	if auth.Version != 0 {
 		b.WriteString(", Version=")
 		b.WriteString(strconv.Itoa(auth.Version))
 	}
 
+	if auth.Algorithm != "" {
+		b.WriteString(", Algorithm=")
+		b.WriteString(auth.Algorithm)
+	}
+
+	if len(auth.SignedHeaders) > 0 {
+		b.WriteString(", SignedHeaders=")
+		b.WriteString(strings.Join(auth.SignedHeaders, ";"))
+	}
+
+	if auth.Scope != "" {
+		b.WriteString(", Scope=")
+		b.WriteString(auth.Scope)
+	}
+
 	res := b.String()
 	return res
 }
@@ -58,13 +97,18 @@ func BuildAuthorizationHeader(auth Authorization) string {
 //
 // 格式为：
 //
-//	Authorization: Scheme Key=value_of_key, Sign=value_of_sign, Timestamp=unix_timestamp, Version=1
+//	Authorization: Scheme Key=value_of_key, Sign=value_of_sign, Timestamp=unix_timestamp, Expires=unix_timestamp, Nonce=random_string, Version=2, Algorithm=HMAC-SHA256, SignedHeaders=host;x-tenant-id, Scope=cn-north/storage
 //
 // 说明：
 //   - 每个 Key 前的空格被忽略。 key-value 对的顺序不做要求。
 //   - Scheme 必须是匹配给定的 @authScheme ，若给定值为空，则使用默认值“SIG-AUTH”。
 //   - Timestamp 签名时的 UNIX 时间戳，单位是秒。
+//   - Expires 可省略，签名过期时的 UNIX 时间戳，单位是秒。
+//   - Nonce 可省略，用于防重放的随机串。
 //   - Version 可省略，省略时默认为 1 。
+//   - Algorithm 可省略，省略时默认为 HMAC-SHA256 。
+//   - SignedHeaders 可省略，参与签名的 HTTP 头的名称，以分号分隔，用于 v2 起的版本。
+//   - Scope 可省略，签名密钥的使用范围，用于 v6 。
 func ParseAuthorizationHeader(r *http.Request, authScheme string) (Authorization, error) {
 	auth := Authorization{}
 
@@ -73,7 +117,7 @@ func ParseAuthorizationHeader(r *http.Request, authScheme string) (Authorization
 	if !ok {
 		headers, ok = r.URL.Query()[_metaParamAuth]
 		if !ok {
-			return auth, fmt.Errorf("missing the Authorization header")
+			return auth, ErrMissingAuthorization
 		}
 	}
 
@@ -103,17 +147,24 @@ func ParseAuthorizationHeader(r *http.Request, authScheme string) (Authorization
 	hasVersion := false
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
-		kv := strings.Split(part, "=")
+		if part == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return auth, fmt.Errorf("Authorization param error: %s", part)
+		}
 
-		switch kv[0] {
+		switch name {
 		case "Key":
-			auth.Key = kv[1]
+			auth.Key = value
 
 		case "Sign":
-			auth.Sign = kv[1]
+			auth.Sign = value
 
 		case "Version":
-			v, err := strconv.Atoi(kv[1])
+			v, err := strconv.Atoi(value)
 			if err != nil {
 				return auth, fmt.Errorf("Authorization version error: %w", err)
 			}
@@ -121,11 +172,32 @@ func ParseAuthorizationHeader(r *http.Request, authScheme string) (Authorization
 			hasVersion = true
 
 		case "Timestamp":
-			v, err := strconv.ParseInt(kv[1], 10, 64)
+			v, err := strconv.ParseInt(value, 10, 64)
 			if err != nil {
 				return auth, fmt.Errorf("Authorization timestamp error: %w", err)
 			}
 			auth.Timestamp = v
+
+		case "Expires":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return auth, fmt.Errorf("Authorization expires error: %w", err)
+			}
+			auth.Expires = v
+
+		case "Nonce":
+			auth.Nonce = value
+
+		case "Algorithm":
+			auth.Algorithm = value
+
+		case "SignedHeaders":
+			if value != "" {
+				auth.SignedHeaders = strings.Split(value, ";")
+			}
+
+		case "Scope":
+			auth.Scope = value
 		}
 	}
 
@@ -156,11 +228,42 @@ type SignResultType int
 
 const (
 	SignResultType_OK                     SignResultType = iota // 签名成功。
-	SignResultType_MissingContentType                           // 当 POST 请求缺少 Content-Type 头时给定此错误。
+	SignResultType_MissingContentType                           // 当签名的 body 非空，但请求缺少 Content-Type 头时给定此错误。
 	SignResultType_UnsupportedContentType                       // 当有 POST 请求有 Content-Type 头，但类型不受支持时给定此错误。
 	SignResultType_InvalidRequestBody                           // 请求的 body 部分缺失或格式错误。
+	SignResultType_UnsupportedVersion                           // 签名算法版本没有注册。
+	SignResultType_UnsupportedAlgorithm                         // 消息认证码算法没有注册，或签名算法不支持。
+	SignResultType_BodyTooLarge                                 // 请求的 body 超过了长度限制。
+	SignResultType_InvalidScope                                 // 签名算法需要 Scope ，但其缺失或格式错误；或签名算法不签名 Scope ，但给出了 Scope 。
+	SignResultType_InvalidSignedHeaders                         // 签名算法不签名 HEADERS 部分，但给出了 SignedHeaders 。
 )
 
+// String 返回签名结果的简要描述。
+func (t SignResultType) String() string {
+	switch t {
+	case SignResultType_OK:
+		return "OK"
+	case SignResultType_MissingContentType:
+		return "missing Content-Type"
+	case SignResultType_UnsupportedContentType:
+		return "unsupported Content-Type"
+	case SignResultType_InvalidRequestBody:
+		return "invalid request body"
+	case SignResultType_UnsupportedVersion:
+		return "unsupported signature version"
+	case SignResultType_UnsupportedAlgorithm:
+		return "unsupported algorithm"
+	case SignResultType_BodyTooLarge:
+		return "request body too large"
+	case SignResultType_InvalidScope:
+		return "invalid scope"
+	case SignResultType_InvalidSignedHeaders:
+		return "invalid signed headers"
+	default:
+		return "SignResultType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
 // AppendSign 计算请求的签名，并将其赋值到请求的 Authorization 头。
 // 调用此方法后， [http.Request.Body] 会被读取并重新置换为新的 [bytes.Buffer] ，旧的 body 会被 Close 。
 //   - r 需要计算签名的请求。
@@ -169,21 +272,27 @@ const (
 //   - authScheme Authorization 头最前面的 Scheme 部分。为空时自动使用 [DefaultAuthScheme] 。
 //   - timestamp UNIX 时间戳，对应 Authorization 头的 Timestamp 字段的值。
 func AppendSign(r *http.Request, accessKey, secret string, authScheme string, timestamp int64) SignResult {
+	return AppendSignWith(r, secret, Authorization{
+		AuthScheme: authScheme,
+		Key:        accessKey,
+		Timestamp:  timestamp,
+		Version:    DefaultSignVersion,
+	})
+}
+
+// AppendSignWith 与 [AppendSign] 相同，但 Authorization 头的各字段由 auth 给出，
+// 其中 Sign 字段会被忽略，替换为计算得到的签名。可用于给出 Nonce 等可选字段。
+// opt 可选，为待签名串的构建选项，需与服务端一致，最多给出一个。
+func AppendSignWith(r *http.Request, secret string, auth Authorization, opt ...SignOption) SignResult {
 	// 追加 Authorization 头的请求基本上是用来发送的，而不是服务器接收到的。
 	// 这种情况下 HTTP body 需要是可用的，故总是设置参数 rewind=true 。
-	res := Sign(r, true, secret, timestamp)
+	res := SignWith(r, true, secret, auth, opt...)
 	if res.Type != SignResultType_OK {
 		return res
 	}
 
-	auth := BuildAuthorizationHeader(Authorization{
-		AuthScheme: authScheme,
-		Key:        accessKey,
-		Sign:       res.Sign,
-		Timestamp:  timestamp,
-		Version:    DefaultSignVersion,
-	})
-	r.Header.Set(HttpHeaderAuthorization, auth)
+	auth.Sign = res.Sign
+	r.Header.Set(HttpHeaderAuthorization, BuildAuthorizationHeader(auth))
 	return res
 }
 
@@ -191,10 +300,32 @@ func AppendSign(r *http.Request, accessKey, secret string, authScheme string, ti
 //   - r 需要计算签名的请求。。
 //   - rewindBody 指定是否需要重用 [http.Request.Body] 。
 //     若为 true ，则读取完 body 后，它会被替换为新的、可重读的 [bytes.Buffer] ，旧的 body 会被 Close 。
-//   - secret HMAC-SHA256 的密钥，使用 UTF-8 字符集。
+//   - secret HMAC 的密钥，使用 UTF-8 字符集。
 //   - timestamp UNIX 时间戳，对应 Authorization 头的 Timestamp 字段的值。
 func Sign(r *http.Request, rewindBody bool, secret string, timestamp int64) SignResult {
-	data, typ, err := buildDataToSign(r, rewindBody, timestamp)
+	return SignWith(r, rewindBody, secret, Authorization{Timestamp: timestamp})
+}
+
+// SignWith 与 [Sign] 相同，但参与签名的字段（如 Timestamp 、 Nonce ）由 auth 给出。
+// 签名算法由 [Authorization.Version] 决定，见 [RegisterSignAlgorithm] ；其为 0 时使用 [DefaultSignVersion] 。
+// opt 可选，为待签名串的构建选项，需与服务端一致，最多给出一个。
+func SignWith(r *http.Request, rewindBody bool, secret string, auth Authorization, opt ...SignOption) SignResult {
+	return sign(r, rewindBody, auth, signOptionOf(opt), func(alg SignAlgorithm, data []byte) (string, error) {
+		return alg.Mac(secret, data, auth)
+	})
+}
+
+// 按 auth 的签名算法版本构建待签名串，并通过 mac 计算签名。
+func sign(r *http.Request, rewindBody bool, auth Authorization, opt SignOption, mac func(alg SignAlgorithm, data []byte) (string, error)) SignResult {
+	alg, ok := LookupSignAlgorithm(signVersion(auth))
+	if !ok {
+		return SignResult{
+			Type:  SignResultType_UnsupportedVersion,
+			Cause: fmt.Errorf("unsupported signature version: %d", auth.Version),
+		}
+	}
+
+	data, typ, err := alg.BuildDataToSign(r, rewindBody, auth, opt)
 	if typ != SignResultType_OK {
 		return SignResult{
 			Type:  typ,
@@ -202,42 +333,172 @@ func Sign(r *http.Request, rewindBody bool, secret string, timestamp int64) Sign
 		}
 	}
 
-	hash := HmacSha256([]byte(secret), data)
+	hash, err := mac(alg, data)
+	if err != nil {
+		return SignResult{
+			Type:  SignResultType_UnsupportedAlgorithm,
+			Cause: err,
+		}
+	}
+
 	return SignResult{
 		Sign: hash,
 	}
 }
 
-// 构建用于签名的串，各部分末尾带一个换行符（ \n ）分割，依次为：
+// 返回 auth 的签名算法版本，未给出时为 [DefaultSignVersion] 。
+func signVersion(auth Authorization) int {
+	if auth.Version == 0 {
+		return DefaultSignVersion
+	}
+	return auth.Version
+}
+
+// 按 auth 的签名算法版本构建待签名串。
+func buildDataToSign(r *http.Request, rewindBody bool, auth Authorization, opt SignOption) ([]byte, SignResultType, error) {
+	alg, ok := LookupSignAlgorithm(signVersion(auth))
+	if !ok {
+		err := fmt.Errorf("unsupported signature version: %d", auth.Version)
+		return nil, SignResultType_UnsupportedVersion, err
+	}
+	return alg.BuildDataToSign(r, rewindBody, auth, opt)
+}
+
+// 内置的 v1 、 v2 、 v5 签名算法，使用 [Authorization.Algorithm] 指定的消息认证码算法。
+type standardSignAlgorithm struct {
+	version        int  // 非 0 时，待签名串以 VERSION 部分开头，使签名不能被当作其他版本的签名使用。
+	signScope      bool // 待签名串是否包含 SCOPE 部分，见 [SignVersionDerived] 。
+	signHeaders    bool // 待签名串是否包含 HEADERS 部分。
+	markEmptyBody  bool // 空 body 是否使用 [_emptyBodyMarker] 表示；否则为空字符串。
+	signAnyBody    bool // 不在 [SignOption.BodyMethods] 之中的方法，是否在带有非空 body 时签名 body ，空 body 是否不要求 Content-Type 。
+	canonicalQuery bool // QUERY 及表单 body 是否使用 [appendCanonicalQueryWithNewLine] 采集。
+	tagBody        bool // BODY 部分是否以 body 的类型开头，如“json:”，使不同类型的 body 不会得到相同的 BODY 部分；否则不支持以摘要签名 body 。
+}
+
+// 签名 body 但 body 为空时， BODY 部分使用的值。
+const _emptyBodyMarker = "~EMPTY-BODY"
+
+// v2 起 BODY 部分的前缀，表示 body 的类型。
+const (
+	_bodyKindForm      = "form:"
+	_bodyKindJson      = "json:"
+	_bodyKindMultipart = "multipart:"
+	_bodyKindDigest    = "sha256:" // 以原始内容的 SHA-256 摘要签名。
+)
+
+// Mac 实现 [SignAlgorithm] 。
+func (x standardSignAlgorithm) Mac(secret string, data []byte, auth Authorization) (string, error) {
+	mac, ok := LookupMacAlgorithm(auth.Algorithm)
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm: %s", auth.Algorithm)
+	}
+	return mac.Mac([]byte(secret), data), nil
+}
+
+// BuildDataToSign 实现 [SignAlgorithm] ，构建用于签名的串，各部分末尾带一个换行符（ \n ）分割，依次为：
+//   - VERSION v2 起有此部分，为固定的“SIGAUTH-V”加上版本号，如“SIGAUTH-V2”。
+//     若没有此部分，修改 Authorization 头的 Version 即可将签名用于按其他版本的规则构建的待签名串，
+//     如将 v5 的签名降级为 v1 ，利用 v1 QUERY 部分的歧义伪造请求。
+//   - SCOPE 仅 [SignVersionDerived] 有此部分，见 [derivedSignAlgorithm.BuildDataToSign] 。
 //   - TIMESTAMP UNIX 时间戳，需和 Authorization 头里的一样。
+//     若 Authorization 头给出了 Expires ，则为“Timestamp,Expires”，如“1661934251,1661937851”。
+//     Expires 与 Timestamp 在同一行，不会与 NONCE 部分混淆。
+//   - NONCE Authorization 头里的 Nonce 。没有 Nonce 时此部分省略（包含换行符）。
 //   - METHOD 是 HTTP 请求的 METHOD ，如 GET/POST 。
+//   - SCHEME 请求的 scheme ，小写，如 https 。仅 [SignOption.SignScheme] 为 true 时有此部分。
+//   - HOST 请求的 authority ，如 temp.org:8080 ，见 [canonicalAuthority] 。仅 [SignOption.SignHost] 为 true 时有此部分。
 //   - PATH 请求的路径，没有路径部分时，使用“/”。
 //     比如请求地址是“http://temp.org/the/path/”则路径为“/the/path/”；
 //     地址是“http://temp.org/”或“http://temp.org”，路径均为“/”。
+//     [SignOption.EscapedPath] 为 true 时，使用规范化后的编码路径。
 //   - QUERY 是 URL 的 query string 部分拼接后的值。
 //     先按参数名称的 UTF-8 字节顺序升序，将参数排列好，需使用稳定的排序算法，这样若有同名参数，其顺序不会被打乱；
 //     然后排序后的参数的值紧密拼接起来（无分隔符）；
 //     若一个参数没有值，如“?a=&b=2”或“?a&b=2”中的“a”，则用参数名称代替值拼入。
 //     没有 query string 时，整个 QUERY 部分使用一个空字符串。
-//   - BODY 若是表单类型，则处理方式同 QUERY ；若是 JSON 请求，则为 JSON 原文。 GET 请求时此部分省略（包含换行符）。
+//     v5 起使用不会产生歧义的规范化格式，见 [appendCanonicalQueryWithNewLine] 。
+//   - HEADERS v2 起有此部分，见 [appendHeadersWithNewLine] 。
+//   - BODY 若是表单类型，则处理方式同 QUERY ；若是 JSON 请求（含 +json 后缀的类型），则为 JSON 原文；
+//     若是 multipart/form-data ，见 [appendMultipartWithNewLine] ；
+//     v2 起，若是 application/octet-stream 、 text/plain 、 text/javascript ，则为原始内容的 SHA-256 摘要（小写 HEX ），
+//     其他类型在 [SignOption.SignUnknownContentTypes] 为 true 时同样处理，否则不受支持； v1 不支持这些类型。
+//     v2 起此部分以 body 的类型开头：表单为“form:”， JSON 为“json:”， multipart/form-data 为“multipart:”，
+//     摘要为“sha256:”，如“json:{"id":1}”，使一种类型的 body 不能被当作另一种类型的 body 使用。
+//     请求方法在 [SignOption.BodyMethods] 之中（默认为 POST 、 PUT 、 PATCH ）时总是有此部分。
+//     v1 的其他方法没有此部分（包含换行符）， body 不被读取；方法在 BodyMethods 之中时，总是要求 Content-Type 。
+//     v2 起，其他方法仅在实际带有非空 body 时有此部分，否则省略（包含换行符）；
+//     body 为空（没有 body 或长度为 0 ）时，不要求 Content-Type ，此部分为固定值“~EMPTY-BODY”。
+//     v2 起可使用 body 的摘要代替 body ，见 [standardSignAlgorithm.appendBodyWithNewLine] 。
 //   - 最后一行固定是“END”。
 //
 // 注意：
 //   - UTF-8 字节顺序不是字典顺序，字节顺序下，英文大写字母在小写字母前面，比如 X 排序在 a 前面。
 //   - 如果在 URL 上使用 ~auth 参数，此参数不参与签名计算。
-func buildDataToSign(r *http.Request, rewindBody bool, timestamp int64) ([]byte, SignResultType, error) {
+func (x standardSignAlgorithm) BuildDataToSign(r *http.Request, rewindBody bool, auth Authorization, opt SignOption) ([]byte, SignResultType, error) {
+	// 不签名的 Scope 可被任意修改，不能交给后续的处理方。
+	if !x.signScope && auth.Scope != "" {
+		err := fmt.Errorf("signature version %d does not support scope", signVersion(auth))
+		return nil, SignResultType_InvalidScope, err
+	}
+
+	// 同理，不签名的 SignedHeaders 会使后续的处理方误以为这些头已被签名。
+	if !x.signHeaders && len(auth.SignedHeaders) > 0 {
+		err := fmt.Errorf("signature version %d does not support signed headers", signVersion(auth))
+		return nil, SignResultType_InvalidSignedHeaders, err
+	}
+
 	buf := new(bytes.Buffer)
 
+	// VERSION
+	if x.version != 0 {
+		buf.WriteString("SIGAUTH-V")
+		buf.WriteString(strconv.Itoa(x.version))
+		buf.WriteRune('\n')
+	}
+
+	// SCOPE
+	if x.signScope {
+		buf.WriteString(derivationDate(auth.Timestamp))
+		buf.WriteRune('/')
+		buf.WriteString(auth.Scope)
+		buf.WriteRune('\n')
+	}
+
 	// TIMESTAMP
-	buf.WriteString(strconv.FormatInt(timestamp, 10))
+	buf.WriteString(strconv.FormatInt(auth.Timestamp, 10))
+	if auth.Expires != 0 {
+		buf.WriteRune(',')
+		buf.WriteString(strconv.FormatInt(auth.Expires, 10))
+	}
 	buf.WriteRune('\n')
 
+	// NONCE
+	if auth.Nonce != "" {
+		buf.WriteString(auth.Nonce)
+		buf.WriteRune('\n')
+	}
+
 	// METHOD
 	buf.WriteString(r.Method)
 	buf.WriteRune('\n')
 
+	// SCHEME 、 HOST
+	if opt.SignScheme || opt.SignHost {
+		scheme, host := requestSchemeAndHost(r, opt)
+		if opt.SignScheme {
+			buf.WriteString(scheme)
+			buf.WriteRune('\n')
+		}
+		if opt.SignHost {
+			buf.WriteString(canonicalAuthority(scheme, host))
+			buf.WriteRune('\n')
+		}
+	}
+
 	// PATH
-	if r.URL.Path == "" {
+	if opt.EscapedPath {
+		buf.WriteString(normalizeEscapedPath(r.URL.EscapedPath(), opt.TrailingSlash))
+	} else if r.URL.Path == "" {
 		buf.WriteRune('/')
 	} else {
 		buf.WriteString(r.URL.Path)
@@ -245,57 +506,180 @@ func buildDataToSign(r *http.Request, rewindBody bool, timestamp int64) ([]byte,
 	buf.WriteRune('\n')
 
 	// QUERY
-	appendQueryWithNewLine(buf, true, r.URL.Query())
+	x.appendQueryWithNewLine(buf, true, r.URL.Query())
+
+	// HEADERS
+	if x.signHeaders {
+		appendHeadersWithNewLine(buf, r, auth.SignedHeaders)
+	}
 
 	// BODY
-	if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+	typ, err := x.appendBodyWithNewLine(buf, r, rewindBody, auth, opt)
+	if typ != SignResultType_OK {
+		return nil, typ, err
+	}
+
+	// END
+	buf.WriteString("END")
+
+	return buf.Bytes(), SignResultType_OK, nil
+}
+
+// 采集 body 部分， required 表示请求方法总是签名 body ，见 [SignOption.BodyMethods] 。
+//   - 若 SignedHeaders 包含 X-Content-SHA256 头（仅限签名 HEADERS 部分的版本），则 body 不被读取，
+//     body 在之后被读取时校验摘要，见 [HttpHeaderContentSHA256] 。 required 时此部分为该头给出的 SHA-256 摘要（带有 [_bodyKindDigest] ），
+//     否则此部分省略，摘要已通过 HEADERS 部分签名。
+//   - v1 只在 required 时读取 body ，并要求 Content-Type ，空 body 同样根据 Content-Type 处理。
+//   - v2 起读取整个 body ， body 非空时根据 Content-Type 处理； body 为空且 required 时写入空 body 的标记；
+//     body 为空且非 required 时此部分省略。
+func (x standardSignAlgorithm) appendBodyWithNewLine(buf *bytes.Buffer, r *http.Request, rewindBody bool, auth Authorization, opt SignOption) (SignResultType, error) {
+	required := opt.isBodyMethod(r.Method)
+	if !x.signAnyBody {
+		// v1 保持原有的行为，已有的客户端据此签名。
+		if !required {
+			return SignResultType_OK, nil
+		}
+
 		contentType, ok := r.Header[HttpHeaderContentType]
 		if !ok {
 			err := fmt.Errorf("missing Content-Type")
-			return nil, SignResultType_MissingContentType, err
+			return SignResultType_MissingContentType, err
 		}
 
 		if r.Body == nil {
 			err := fmt.Errorf("missing body for %s", contentType[0])
-			return nil, SignResultType_InvalidRequestBody, err
+			return SignResultType_InvalidRequestBody, err
+		}
+	}
+
+	if x.signHeaders && isHeaderSigned(auth.SignedHeaders, HttpHeaderContentSHA256) {
+		digest := r.Header.Get(HttpHeaderContentSHA256)
+		if !isSha256Hex(digest) {
+			err := fmt.Errorf("invalid %s: %q", HttpHeaderContentSHA256, digest)
+			return SignResultType_InvalidRequestBody, err
 		}
 
-		// 对于流的读取，这类错误通常不应该发生，若发生,使用 panic 处理，使请求终止与 500 internal error 。
-		// 其他诸如格式错误等，则作为普通错误返回。
-		var body []byte
-		var err error
+		verifyBodyDigest(r, digest)
+		if required {
+			x.appendDigestWithNewLine(buf, digest)
+		}
+		return SignResultType_OK, nil
+	}
+
+	// 对于流的读取，这类错误通常不应该发生，若发生,使用 panic 处理，使请求终止与 500 internal error 。
+	// 其他诸如格式错误、 body 超过长度限制等，则作为普通错误返回。
+	var body []byte
+	var err error
+	if r.Body != nil && r.Body != http.NoBody {
 		if rewindBody {
 			body, err = repeatableReadBody(r)
 		} else {
 			body, err = io.ReadAll(r.Body)
 		}
+	}
 
-		if err != nil {
-			panic(err)
+	if err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return SignResultType_BodyTooLarge, err
 		}
+		panic(err)
+	}
 
-		switch contentType[0] {
-		case ContentTypeForm:
-			values, err := url.ParseQuery(string(body))
-			if err != nil {
-				return nil, SignResultType_InvalidRequestBody, err
+	if len(body) == 0 && x.signAnyBody {
+		if required {
+			if x.markEmptyBody {
+				buf.WriteString(_emptyBodyMarker)
 			}
-			appendQueryWithNewLine(buf, false, values)
-
-		case ContentTypeJson:
-			buf.Write(body)
 			buf.WriteRune('\n')
+		}
+		return SignResultType_OK, nil
+	}
 
-		default:
-			err := fmt.Errorf("unsupported Content-Type: %s", contentType[0])
-			return nil, SignResultType_UnsupportedContentType, err
+	contentType, ok := r.Header[HttpHeaderContentType]
+	if !ok {
+		err := fmt.Errorf("missing Content-Type")
+		return SignResultType_MissingContentType, err
+	}
+
+	// 媒体类型不区分大小写， charset 等参数不影响处理方式。
+	// 参数本身不参与此部分签名，需要时可将 Content-Type 头加入 SignedHeaders 。
+	mediaType, params, err := mime.ParseMediaType(contentType[0])
+	if err != nil {
+		err := fmt.Errorf("unsupported Content-Type: %s", contentType[0])
+		return SignResultType_UnsupportedContentType, err
+	}
+
+	switch {
+	case mediaType == ContentTypeForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return SignResultType_InvalidRequestBody, err
+		}
+		x.appendBodyKind(buf, _bodyKindForm)
+		x.appendQueryWithNewLine(buf, false, values)
+
+	case isJsonMediaType(mediaType):
+		x.appendBodyKind(buf, _bodyKindJson)
+		buf.Write(body)
+		buf.WriteRune('\n')
+
+	case mediaType == ContentTypeMultipartForm:
+		x.appendBodyKind(buf, _bodyKindMultipart)
+		if err := appendMultipartWithNewLine(buf, body, params["boundary"], func(buf *bytes.Buffer, fields url.Values) {
+			x.appendQueryWithNewLine(buf, false, fields)
+		}); err != nil {
+			return SignResultType_InvalidRequestBody, err
 		}
+
+	case x.tagBody && (isDigestMediaType(mediaType) || opt.SignUnknownContentTypes):
+		sum := sha256.Sum256(body)
+		x.appendDigestWithNewLine(buf, hex.EncodeToString(sum[:]))
+
+	default:
+		err := fmt.Errorf("unsupported Content-Type: %s", contentType[0])
+		return SignResultType_UnsupportedContentType, err
 	}
 
-	// END
-	buf.WriteString("END")
+	return SignResultType_OK, nil
+}
 
-	return buf.Bytes(), SignResultType_OK, nil
+// 写入 BODY 部分表示 body 类型的前缀，仅限 tagBody 的版本。
+func (x standardSignAlgorithm) appendBodyKind(buf *bytes.Buffer, kind string) {
+	if x.tagBody {
+		buf.WriteString(kind)
+	}
+}
+
+// 将小写 HEX 格式的 SHA-256 摘要作为 BODY 部分写入。
+func (x standardSignAlgorithm) appendDigestWithNewLine(buf *bytes.Buffer, digest string) {
+	x.appendBodyKind(buf, _bodyKindDigest)
+	buf.WriteString(digest)
+	buf.WriteRune('\n')
+}
+
+// 判断是否为以原始内容的 SHA-256 摘要签名的媒体类型。 mediaType 需已转为小写。
+func isDigestMediaType(mediaType string) bool {
+	switch mediaType {
+	case ContentTypeBinary, ContentTypePlainText, ContentTypeJavascript:
+		return true
+	default:
+		return false
+	}
+}
+
+// 判断是否为 JSON 媒体类型，包括带 +json 结构化后缀的类型，如 application/vnd.api+json 。
+// mediaType 需已转为小写。
+func isJsonMediaType(mediaType string) bool {
+	return mediaType == ContentTypeJson || strings.HasSuffix(mediaType, "+json")
+}
+
+// 按签名算法采集 query 键值对 或者 form body 键值对。
+func (x standardSignAlgorithm) appendQueryWithNewLine(buf *bytes.Buffer, fromUrl bool, query url.Values) {
+	if x.canonicalQuery {
+		appendCanonicalQueryWithNewLine(buf, fromUrl, query)
+	} else {
+		appendQueryWithNewLine(buf, fromUrl, query)
+	}
 }
 
 // 采集 query 键值对 或者 form body 键值对
@@ -322,6 +706,73 @@ func appendQueryWithNewLine(buf *bytes.Buffer, fromUrl bool, query url.Values) {
 	buf.WriteRune('\n')
 }
 
+// 采集参与签名的 HTTP 头，格式为：
+//   - 首行是规范化后的头名称，以分号分隔，没有头时为空行。
+//   - 之后每个头一行，依次为“名称:值”。
+//
+// 规范化规则：
+//   - 名称转为小写，按 UTF-8 字节顺序升序排列，并去重。
+//   - 值去掉首尾空白；同名头有多个值时，以逗号拼接。请求中没有此头时，值为空。
+//   - host 头取 [http.Request.Host] ，其为空时取 URL 上的 Host 。
+func appendHeadersWithNewLine(buf *bytes.Buffer, r *http.Request, signedHeaders []string) {
+	names := canonicalHeaderNames(signedHeaders)
+	buf.WriteString(strings.Join(names, ";"))
+	buf.WriteRune('\n')
+
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteRune(':')
+
+		if name == "host" {
+			if r.Host != "" {
+				buf.WriteString(r.Host)
+			} else {
+				buf.WriteString(r.URL.Host)
+			}
+		} else {
+			values := r.Header.Values(name)
+			for i, v := range values {
+				if i > 0 {
+					buf.WriteRune(',')
+				}
+				buf.WriteString(strings.TrimSpace(v))
+			}
+		}
+
+		buf.WriteRune('\n')
+	}
+}
+
+// 判断 HTTP 头 name 是否在 signedHeaders 之中，不区分大小写。
+func isHeaderSigned(signedHeaders []string, name string) bool {
+	for _, h := range signedHeaders {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 将 HTTP 头的名称转为小写，排序并去重。
+func canonicalHeaderNames(headers []string) []string {
+	names := make([]string, 0, len(headers))
+	for _, h := range headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" {
+			names = append(names, h)
+		}
+	}
+	sort.Strings(names)
+
+	res := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
 // 读取整个 [http.Request.Body] 并返回读取到数据。
 // 读取完毕后，原 body 会被关闭， Body 字段被替换为新的、未被读取的 [bytes.Buffer] ，其包含读取到数据。
 // 此方法用于处理 body 的重复读取。
@@ -2,6 +2,7 @@ package sigauth
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"testing"
@@ -31,6 +32,39 @@ func TestBuildAuthorizationHeader(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("%s Key=kk, Sign=ss, Timestamp=123", DefaultAuthScheme), res)
 	})
 
+	t.Run("HasNonce", func(t *testing.T) {
+		res := BuildAuthorizationHeader(Authorization{
+			Key:       "kk",
+			Sign:      "ss",
+			Timestamp: 123,
+			Nonce:     "nn",
+			Version:   1,
+		})
+		assert.Equal(t, fmt.Sprintf("%s Key=kk, Sign=ss, Timestamp=123, Nonce=nn, Version=1", DefaultAuthScheme), res)
+	})
+
+	t.Run("HasExpires", func(t *testing.T) {
+		res := BuildAuthorizationHeader(Authorization{
+			Key:       "kk",
+			Sign:      "ss",
+			Timestamp: 123,
+			Expires:   456,
+			Nonce:     "nn",
+		})
+		assert.Equal(t, fmt.Sprintf("%s Key=kk, Sign=ss, Timestamp=123, Expires=456, Nonce=nn", DefaultAuthScheme), res)
+	})
+
+	t.Run("HasSignedHeaders", func(t *testing.T) {
+		res := BuildAuthorizationHeader(Authorization{
+			Key:           "kk",
+			Sign:          "ss",
+			Timestamp:     123,
+			Version:       2,
+			SignedHeaders: []string{"host", "x-tenant-id"},
+		})
+		assert.Equal(t, fmt.Sprintf("%s Key=kk, Sign=ss, Timestamp=123, Version=2, SignedHeaders=host;x-tenant-id", DefaultAuthScheme), res)
+	})
+
 	t.Run("CustomScheme", func(t *testing.T) {
 		res := BuildAuthorizationHeader(Authorization{
 			AuthScheme: "CUSTOM",
@@ -111,6 +145,30 @@ func TestParseAuthorizationHeader(t *testing.T) {
 		assert.Equal(t, 123, auth.Version)
 	})
 
+	t.Run("OK-Nonce", func(t *testing.T) {
+		auth, err := do("", fmt.Sprintf("%s Key=kk, Nonce=abc", DefaultAuthScheme))
+		require.NoError(t, err)
+		assert.Equal(t, "abc", auth.Nonce)
+	})
+
+	t.Run("OK-Expires", func(t *testing.T) {
+		auth, err := do("", fmt.Sprintf("%s Key=kk, Timestamp=1, Expires=301", DefaultAuthScheme))
+		require.NoError(t, err)
+		assert.Equal(t, int64(301), auth.Expires)
+	})
+
+	t.Run("ErrorExpires", func(t *testing.T) {
+		_, err := do("", fmt.Sprintf("%s Key=kk, Expires=x", DefaultAuthScheme))
+		require.Error(t, err)
+	})
+
+	t.Run("OK-SignedHeaders", func(t *testing.T) {
+		auth, err := do("", fmt.Sprintf("%s Key=kk, Version=2, SignedHeaders=host;x-a", DefaultAuthScheme))
+		require.NoError(t, err)
+		assert.Equal(t, 2, auth.Version)
+		assert.Equal(t, []string{"host", "x-a"}, auth.SignedHeaders)
+	})
+
 	t.Run("OK-DefaultVersion", func(t *testing.T) {
 		auth, err := do("", fmt.Sprintf("%s Key=kk", DefaultAuthScheme))
 		require.NoError(t, err)
@@ -163,7 +221,7 @@ func Test_buildDataToSign(t *testing.T) {
 			_requestTypeGet,
 			"",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_OK, typ)
 		assert.Nil(t, err)
 
@@ -177,7 +235,7 @@ func Test_buildDataToSign(t *testing.T) {
 			_requestTypeGet,
 			"",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_OK, typ)
 		assert.Nil(t, err)
 
@@ -191,7 +249,7 @@ func Test_buildDataToSign(t *testing.T) {
 			_requestTypeGet,
 			"",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_OK, typ)
 		assert.Nil(t, err)
 
@@ -207,7 +265,7 @@ func Test_buildDataToSign(t *testing.T) {
 			_requestTypeForm,
 			"bb=22&aa=11&dd&&cc=33",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_OK, typ)
 		assert.Nil(t, err)
 
@@ -221,7 +279,7 @@ func Test_buildDataToSign(t *testing.T) {
 			_requestTypeJson,
 			`{"Data":"value"}`,
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_OK, typ)
 		assert.Nil(t, err)
 
@@ -229,30 +287,286 @@ func Test_buildDataToSign(t *testing.T) {
 		assert.Equal(t, want, string(data))
 	})
 
+	t.Run("ContentTypeParams", func(t *testing.T) {
+		for _, contentType := range []string{
+			"application/json; charset=utf-8",
+			"Application/JSON",
+			"application/vnd.api+json",
+			"application/merge-patch+json; charset=UTF-8",
+		} {
+			r := newRequest("", "/p", _requestTypeJson, `{"a":1}`)
+			r.Header.Set(HttpHeaderContentType, contentType)
+
+			data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
+			assert.Equal(t, SignResultType_OK, typ, contentType)
+			assert.Nil(t, err)
+			assert.Equal(t, "12345\nPOST\n/p\n\n{\"a\":1}\nEND", string(data), contentType)
+		}
+
+		r := newRequest("", "/p", _requestTypeForm, "a=1")
+		r.Header.Set(HttpHeaderContentType, "application/x-www-form-urlencoded; charset=utf-8")
+		data, typ, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Equal(t, "12345\nPOST\n/p\n\n1\nEND", string(data))
+	})
+
+	t.Run("ContentTypeParamsSigned", func(t *testing.T) {
+		auth := Authorization{
+			Timestamp:     12345,
+			Version:       SignVersion2,
+			SignedHeaders: []string{HttpHeaderContentType},
+		}
+
+		r := newRequest("", "/p", _requestTypeJson, "{}")
+		r.Header.Set(HttpHeaderContentType, "application/json; charset=utf-8")
+		data, _, _ := buildDataToSign(r, false, auth, SignOption{})
+
+		r = newRequest("", "/p", _requestTypeJson, "{}")
+		r.Header.Set(HttpHeaderContentType, "application/json; charset=gbk")
+		other, _, _ := buildDataToSign(r, false, auth, SignOption{})
+
+		assert.NotEqual(t, string(data), string(other))
+	})
+
+	t.Run("ErrorUnsupportedContentType", func(t *testing.T) {
+		for _, contentType := range []string{"application/xml", "application/json+x", "bad;;"} {
+			r := newRequest("", "/p", _requestTypeJson, "{}")
+			r.Header.Set(HttpHeaderContentType, contentType)
+
+			_, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
+			assert.Equal(t, SignResultType_UnsupportedContentType, typ, contentType)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("DigestContentTypes", func(t *testing.T) {
+		for _, contentType := range []string{
+			ContentTypeBinary,
+			ContentTypePlainText + "; charset=utf-8",
+			ContentTypeJavascript,
+		} {
+			r := newRequest("", "/p", _requestTypeJson, "abc")
+			r.Header.Set(HttpHeaderContentType, contentType)
+
+			data, typ, err := buildDataToSign(r, true, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+			assert.Equal(t, SignResultType_OK, typ, contentType)
+			assert.Nil(t, err)
+			assert.Equal(t, "SIGAUTH-V2\n12345\nPOST\n/p\n\n\nsha256:"+_sha256OfAbc+"\nEND", string(data), contentType)
+
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "abc", string(body))
+
+			// v1 不支持以摘要签名 body 。
+			_, typ, _ = buildDataToSign(r, true, Authorization{Timestamp: 12345}, SignOption{})
+			assert.Equal(t, SignResultType_UnsupportedContentType, typ, contentType)
+		}
+	})
+
+	t.Run("UnknownContentType", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeJson, "abc")
+		r.Header.Set(HttpHeaderContentType, "application/x-protobuf")
+
+		v2 := Authorization{Timestamp: 12345, Version: SignVersion2}
+		_, typ, _ := buildDataToSign(r, true, v2, SignOption{})
+		assert.Equal(t, SignResultType_UnsupportedContentType, typ)
+
+		data, typ, err := buildDataToSign(r, true, v2, SignOption{SignUnknownContentTypes: true})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nPOST\n/p\n\n\nsha256:"+_sha256OfAbc+"\nEND", string(data))
+
+		_, typ, _ = buildDataToSign(r, true, Authorization{Timestamp: 12345}, SignOption{SignUnknownContentTypes: true})
+		assert.Equal(t, SignResultType_UnsupportedContentType, typ)
+	})
+
+	t.Run("BodyKindNotAmbiguous", func(t *testing.T) {
+		// Content-Type 默认不被签名，不同类型的 body 不能得到相同的 BODY 部分，否则可以互相替换。
+		newBody := func(contentType, body string) *http.Request {
+			r := newRequest("", "/p", _requestTypeJson, body)
+			r.Header.Set(HttpHeaderContentType, contentType)
+			return r
+		}
+		bodies := []*http.Request{
+			newBody(ContentTypeBinary, "abc"),
+			newBody(ContentTypeForm, "x=sha256%3A"+_sha256OfAbc),
+			newBody(ContentTypeJson, "sha256:"+_sha256OfAbc),
+			newBody(ContentTypeJson, "form:x"),
+			newBody(ContentTypeForm, "x"),
+			newBody(ContentTypeJson, _emptyBodyMarker),
+			newBody(ContentTypeJson, ""),
+		}
+
+		for _, version := range []int{SignVersion2, SignVersion5, SignVersionEd25519, SignVersionEcdsaP256} {
+			auth := Authorization{Timestamp: 12345, Version: version}
+			seen := make(map[string]int)
+			for i, r := range bodies {
+				data, typ, err := buildDataToSign(r, true, auth, SignOption{})
+				require.Equal(t, SignResultType_OK, typ, err)
+				if j, ok := seen[string(data)]; ok {
+					t.Errorf("v%d: body %d and %d have the same data to sign: %q", version, j, i, data)
+				}
+				seen[string(data)] = i
+			}
+		}
+	})
+
+	t.Run("Nonce", func(t *testing.T) {
+		r := newRequest("",
+			"/p?x=x",
+			_requestTypeGet,
+			"",
+		)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345, Nonce: "abc"}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+
+		want := "12345\nabc\nGET\n/p\nx\nEND"
+		assert.Equal(t, want, string(data))
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeGet, "")
+		data, _, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345, Expires: 12645, Nonce: "abc"}, SignOption{})
+		assert.Equal(t, "12345,12645\nabc\nGET\n/p\n\nEND", string(data))
+
+		// Expires 不能被移到 Nonce 。
+		moved, _, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345, Nonce: "12645"}, SignOption{})
+		assert.NotEqual(t, string(data), string(moved))
+	})
+
+	t.Run("SignedHeaders", func(t *testing.T) {
+		r := newRequest("http://Temp.org:8080",
+			"/p",
+			_requestTypeJson,
+			"{}",
+		)
+		r.Header.Add("X-Tenant-Id", " t1 ")
+		r.Header.Add("X-Tenant-Id", "t2")
+
+		auth := Authorization{
+			Timestamp:     12345,
+			Version:       SignVersion2,
+			SignedHeaders: []string{"X-Tenant-Id", "Content-Type", "host", "x-missing", "content-type"},
+		}
+		data, typ, err := buildDataToSign(r, false, auth, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+
+		want := "SIGAUTH-V2\n12345\nPOST\n/p\n\n" +
+			"content-type;host;x-missing;x-tenant-id\n" +
+			"content-type:application/json\n" +
+			"host:Temp.org:8080\n" +
+			"x-missing:\n" +
+			"x-tenant-id:t1,t2\n" +
+			"json:{}\nEND"
+		assert.Equal(t, want, string(data))
+	})
+
+	t.Run("NoSignedHeaders", func(t *testing.T) {
+		r := newRequest("", "/", _requestTypeGet, "")
+		data, typ, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nGET\n/\n\n\nEND", string(data))
+	})
+
 	t.Run("ErrorBadForm", func(t *testing.T) {
 		r := newRequest("",
 			"",
 			_requestTypeForm,
 			"",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_InvalidRequestBody, typ)
 		assert.Nil(t, data)
 		require.Error(t, err)
 	})
 
+	t.Run("ErrorInvalidFormEncoding", func(t *testing.T) {
+		r := newRequest("", "", _requestTypeForm, "a=%zz")
+		for _, version := range []int{DefaultSignVersion, SignVersion2} {
+			data, typ, err := buildDataToSign(r, true, Authorization{Timestamp: 12345, Version: version}, SignOption{})
+			assert.Equal(t, SignResultType_InvalidRequestBody, typ, version)
+			assert.Nil(t, data)
+			require.Error(t, err)
+		}
+	})
+
 	t.Run("ErrorNilJsonBody", func(t *testing.T) {
 		r := newRequest("",
 			"",
 			_requestTypeJson,
 			"",
 		)
-		data, typ, err := buildDataToSign(r, false, 12345)
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
 		assert.Equal(t, SignResultType_InvalidRequestBody, typ)
 		assert.Nil(t, data)
 		require.Error(t, err)
 		require.Regexp(t, "missing body", err.Error())
 	})
+
+	t.Run("EmptyBody", func(t *testing.T) {
+		// v1 总是要求 Content-Type 。
+		r := newRequest("", "", _requestTypeGet, "")
+		r.Method = http.MethodPost
+
+		_, typ, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
+		assert.Equal(t, SignResultType_MissingContentType, typ)
+
+		// v2 起空 body 不要求 Content-Type 。
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nPOST\n/\n\n\n~EMPTY-BODY\nEND", string(data))
+
+		// 长度为 0 的 body 与没有 body 相同。
+		r = newRequest("", "", _requestTypeJson, "")
+		r.Body = http.NoBody
+		data2, _, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+		assert.Equal(t, string(data), string(data2))
+	})
+
+	t.Run("OtherMethodWithBody", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeJson, `{"id":1}`)
+		r.Method = http.MethodDelete
+
+		data, typ, err := buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nDELETE\n/p\n\n\njson:{\"id\":1}\nEND", string(data))
+
+		// v1 保持原有的行为，不签名 DELETE 的 body ，也不读取 body 。
+		r = newRequest("", "/p", _requestTypeJson, `{"id":1}`)
+		r.Method = http.MethodDelete
+		data, typ, err = buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{})
+		assert.Equal(t, SignResultType_OK, typ)
+		assert.Nil(t, err)
+		assert.Equal(t, "12345\nDELETE\n/p\n\nEND", string(data))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"id":1}`, string(body))
+
+		// 给定 BodyMethods 后， v1 同样签名 DELETE 的 body 。
+		r = newRequest("", "/p", _requestTypeJson, `{"id":1}`)
+		r.Method = http.MethodDelete
+		data, _, _ = buildDataToSign(r, false, Authorization{Timestamp: 12345}, SignOption{BodyMethods: []string{http.MethodDelete}})
+		assert.Equal(t, "12345\nDELETE\n/p\n\n{\"id\":1}\nEND", string(data))
+	})
+
+	t.Run("OtherMethodWithoutBody", func(t *testing.T) {
+		r := newRequest("", "/p", _requestTypeGet, "")
+		r.Method = http.MethodDelete
+
+		data, _, _ := buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, SignOption{})
+		assert.Equal(t, "SIGAUTH-V2\n12345\nDELETE\n/p\n\n\nEND", string(data))
+
+		opt := SignOption{BodyMethods: []string{http.MethodDelete, "PURGE"}}
+		data, _, _ = buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, opt)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nDELETE\n/p\n\n\n~EMPTY-BODY\nEND", string(data))
+
+		// 给定 BodyMethods 后，不在其中的 POST 仅在有 body 时签名 body 。
+		r.Method = http.MethodPost
+		data, _, _ = buildDataToSign(r, false, Authorization{Timestamp: 12345, Version: SignVersion2}, opt)
+		assert.Equal(t, "SIGAUTH-V2\n12345\nPOST\n/p\n\n\nEND", string(data))
+	})
 }
 
 // 测试完整签名
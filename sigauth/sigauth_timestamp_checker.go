@@ -40,3 +40,54 @@ func MaxDeviationTimeChecker(maxDeviation int64) TimeCheckerFunc {
 		return nil
 	}
 }
+
+// ExpiryTimeCheckerFunc 与 [TimeCheckerFunc] 相同，但同时接收 Authorization 的 Expires 字段，
+// 用于校验带有效期的签名。 expires 为 0 表示没有给出 Expires 。
+type ExpiryTimeCheckerFunc func(timestamp, expires int64) error
+
+// DefaultExpiryTimeChecker 是默认的 [ExpiryTimeCheckerFunc] ，等同于 MaxDeviationExpiryTimeChecker(300) 。
+var DefaultExpiryTimeChecker ExpiryTimeCheckerFunc = MaxDeviationExpiryTimeChecker(300)
+
+// MaxDeviationExpiryTimeChecker 返回一个 [ExpiryTimeCheckerFunc] ：
+//   - 没有 Expires 时，同 [MaxDeviationTimeChecker] 。
+//   - 有 Expires 时，时间戳不能晚于当前时间 maxDeviation 秒以上，且当前时间不能晚于 Expires 。
+func MaxDeviationExpiryTimeChecker(maxDeviation int64) ExpiryTimeCheckerFunc {
+	timeChecker := MaxDeviationTimeChecker(maxDeviation)
+	return func(timestamp, expires int64) error {
+		if expires == 0 {
+			return timeChecker(timestamp)
+		}
+
+		now := time.Now().Unix()
+		if timestamp-now > maxDeviation {
+			return fmt.Errorf("the timestamp should not be later than the time for more than %ds, the time is %d, got %d", maxDeviation, now, timestamp)
+		}
+		return checkExpires(now, expires)
+	}
+}
+
+// WithExpiry 将 [TimeCheckerFunc] 转为 [ExpiryTimeCheckerFunc] ：没有 Expires 时使用原方法校验；
+// 有 Expires 时，时间戳晚于当前时间的，仍使用原方法校验，以限制时间戳超前的程度；早于当前时间的不受原方法的限制，
+// 同时校验当前时间不晚于 Expires 。
+func (f TimeCheckerFunc) WithExpiry() ExpiryTimeCheckerFunc {
+	return func(timestamp, expires int64) error {
+		if expires == 0 {
+			return f(timestamp)
+		}
+
+		now := time.Now().Unix()
+		if timestamp > now {
+			if err := f(timestamp); err != nil {
+				return err
+			}
+		}
+		return checkExpires(now, expires)
+	}
+}
+
+func checkExpires(now, expires int64) error {
+	if now > expires {
+		return fmt.Errorf("the signature expired at %d, the time is %d", expires, now)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package sigauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxDeviationExpiryTimeChecker(t *testing.T) {
+	checker := MaxDeviationExpiryTimeChecker(300)
+	now := time.Now().Unix()
+
+	assert.NoError(t, checker(now, 0))
+	assert.Error(t, checker(now-301, 0))
+
+	// 有 Expires 时，可以早于当前时间任意长。
+	assert.NoError(t, checker(now-3600, now+60))
+	assert.Error(t, checker(now-3600, now-1))
+	assert.Error(t, checker(now+301, now+600))
+}
+
+func TestTimeCheckerFunc_WithExpiry(t *testing.T) {
+	errTime := errors.New("time")
+	checker := TimeCheckerFunc(func(timestamp int64) error {
+		return errTime
+	}).WithExpiry()
+	now := time.Now().Unix()
+
+	assert.ErrorIs(t, checker(now, 0), errTime)
+	assert.NoError(t, checker(now, now+60))
+	assert.Error(t, checker(now-120, now-60))
+
+	t.Run("FutureTimestamp", func(t *testing.T) {
+		checker := MaxDeviationTimeChecker(300).WithExpiry()
+		now := time.Now().Unix()
+
+		assert.NoError(t, checker(now-3600, now+60))
+		assert.NoError(t, checker(now+60, now+120))
+
+		// 时间戳超前过多，即便 Expires 未过期也不通过。
+		future := now + 365*24*3600
+		assert.Error(t, checker(future, future+60))
+	})
+}
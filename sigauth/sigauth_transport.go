@@ -0,0 +1,140 @@
+package sigauth
+
+import (
+	"crypto"
+	"net/http"
+	"time"
+)
+
+// Transport 是一个 [http.RoundTripper] ，在发送请求前，通过 [AppendSignWith] 为其追加签名。
+// 将其设置为 [http.Client.Transport] ，即可得到自动签名的客户端：
+//
+//	client := &http.Client{
+//		Transport: &sigauth.Transport{AccessKey: "key", Secret: "secret"},
+//	}
+//
+// POST 等带 body 的请求仍需自行设置 Content-Type 头。
+type Transport struct {
+	// 实际发送请求的 [http.RoundTripper] 。若为 nil ，则使用 [http.DefaultTransport] 。
+	Base http.RoundTripper
+
+	// 对应 Authorization 头中的 Key 字段的值。
+	AccessKey string
+
+	// HMAC 的密钥，使用 UTF-8 字符集。
+	Secret string
+
+	// 用于非对称签名的私钥，给定时忽略 Secret 。
+	PrivateKey crypto.Signer
+
+	// Authorization 头最前面的 Scheme 部分。为空时自动使用 [DefaultAuthScheme] 。
+	AuthScheme string
+
+	// 用于获取签名时的时间戳。若为 nil ，则使用 [time.Now] 。
+	Now func() time.Time
+
+	// 签名的有效期。若大于 0 ，则 Authorization 带有 Expires 字段，其值为时间戳加上此有效期（向上取整到秒）。
+	// 服务端需给定 [SigAuthHandlerOption.MaxLifetime] 才接受这类签名。
+	Lifetime time.Duration
+
+	// 签名算法版本。若为 0 ：给定了 PrivateKey 时，使用 [PrivateKeySignVersion] 的结果；
+	// 给定了 Scope 时使用 [SignVersionDerived] ；给定了 SignedHeaders 时使用 [SignVersion2] ；
+	// 否则使用 [DefaultSignVersion] 。
+	Version int
+
+	// 签名密钥的使用范围，用于 [SignVersionDerived] ，见 [Authorization.Scope] 。
+	Scope string
+
+	// 消息认证码算法，见 [RegisterMacAlgorithm] 。若为空，则使用 [DefaultMacAlgorithm] 。
+	Algorithm string
+
+	// 参与签名的 HTTP 头的名称，所选的签名算法版本需支持此字段。
+	SignedHeaders []string
+
+	// 是否以 body 的摘要代替 body 参与签名，见 [HttpHeaderContentSHA256] 。
+	// 为 true 时，通过 [SetContentSHA256] 设置摘要，并将其加入 SignedHeaders 。
+	// 所选的签名算法版本需支持 SignedHeaders 。
+	StreamBody bool
+
+	// 用于为每个请求生成 Nonce ，如 [RandomNonce] 。若为 nil ，则不带 Nonce 。
+	NewNonce func() string
+
+	// 待签名串的构建选项，如 BodyMethods ，需与服务端一致。
+	SignOption
+}
+
+// RoundTrip 实现 [http.RoundTripper] 。
+// 原请求不会被修改，签名追加在其副本上。签名失败时不发送请求，返回 [*SignError] 。
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper 不应修改原请求，在副本上签名。副本与原请求共用 Body ，签名时读取并替换副本的 Body 。
+	r := req.Clone(req.Context())
+
+	signedHeaders := t.SignedHeaders
+	if t.StreamBody {
+		if err := SetContentSHA256(r); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		signedHeaders = append(signedHeaders[:len(signedHeaders):len(signedHeaders)], HttpHeaderContentSHA256)
+	}
+
+	auth := Authorization{
+		AuthScheme:    t.AuthScheme,
+		Key:           t.AccessKey,
+		Timestamp:     t.now().Unix(),
+		Version:       t.Version,
+		Algorithm:     t.Algorithm,
+		SignedHeaders: signedHeaders,
+		Scope:         t.Scope,
+	}
+	if auth.Version == 0 {
+		switch {
+		case t.PrivateKey != nil:
+			auth.Version = PrivateKeySignVersion(t.PrivateKey)
+		case auth.Scope != "":
+			auth.Version = SignVersionDerived
+		case len(auth.SignedHeaders) > 0:
+			auth.Version = SignVersion2
+		default:
+			auth.Version = DefaultSignVersion
+		}
+	}
+	if t.Lifetime > 0 {
+		auth.Expires = auth.Timestamp + int64((t.Lifetime+time.Second-1)/time.Second)
+	}
+	if t.NewNonce != nil {
+		auth.Nonce = t.NewNonce()
+	}
+
+	var res SignResult
+	if t.PrivateKey != nil {
+		res = AppendSignWithPrivateKey(r, t.PrivateKey, auth, t.SignOption)
+	} else {
+		res = AppendSignWith(r, t.Secret, auth, t.SignOption)
+	}
+	if err := res.err(); err != nil {
+		// RoundTripper 在出错时也需要关闭 body 。
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.base().RoundTrip(r)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) now() time.Time {
+	if t.Now == nil {
+		return time.Now()
+	}
+	return t.Now()
+}
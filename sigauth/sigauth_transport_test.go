@@ -0,0 +1,143 @@
+package sigauth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, _ := AuthorizationFromContext(r.Context())
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(auth.Key + ":" + string(body)))
+	})))
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			AccessKey: _key,
+			Secret:    _secret,
+			Now: func() time.Time {
+				return time.Unix(_timestamp, 0)
+			},
+		},
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, s.URL+"/p?x=1", strings.NewReader(`{"a":1}`))
+		r.Header.Set(HttpHeaderContentType, ContentTypeJson)
+
+		res, err := client.Do(r)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `testKey:{"a":1}`, string(body))
+
+		// 原请求没有被修改。
+		assert.Empty(t, r.Header.Get(HttpHeaderAuthorization))
+	})
+
+	t.Run("SignError", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodPost, s.URL, strings.NewReader(`{}`))
+
+		_, err := client.Do(r)
+		require.Error(t, err)
+
+		var signErr *SignError
+		require.True(t, errors.As(err, &signErr))
+		assert.Equal(t, SignResultType_MissingContentType, signErr.Type)
+	})
+}
+
+func TestTransport_bodyMethods(t *testing.T) {
+	opt := SignOption{BodyMethods: []string{http.MethodPost, http.MethodDelete, "PURGE"}}
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		TimeChecker:  NoTimeChecker,
+		SignOption:   opt,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + ":" + string(body)))
+	})))
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: &Transport{
+			AccessKey:  _key,
+			Secret:     _secret,
+			Version:    SignVersion2,
+			SignOption: opt,
+		},
+	}
+
+	for _, c := range []struct {
+		method, body string
+	}{
+		{"PURGE", ""},
+		{http.MethodDelete, `{"id":1}`},
+		{http.MethodPost, ""},
+		{"QUERY", `{"q":1}`},
+	} {
+		r, _ := http.NewRequest(c.method, s.URL+"/p", strings.NewReader(c.body))
+		r.Header.Set(HttpHeaderContentType, ContentTypeJson)
+
+		res, err := client.Do(r)
+		require.NoError(t, err, c.method)
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, c.method+":"+c.body, string(body), c.method)
+	}
+
+	t.Run("TamperedDeleteBody", func(t *testing.T) {
+		signed, _ := http.NewRequest(http.MethodDelete, s.URL+"/p", nil)
+		require.Equal(t, SignResultType_OK, AppendSignWith(signed, _secret, Authorization{
+			Key:       _key,
+			Timestamp: time.Now().Unix(),
+			Version:   SignVersion2,
+		}, opt).Type)
+
+		r, _ := http.NewRequest(http.MethodDelete, s.URL+"/p", strings.NewReader(`{"id":2}`))
+		r.Header = signed.Header.Clone()
+		r.Header.Set(HttpHeaderContentType, ContentTypeJson)
+
+		res, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+}
+
+func TestTransport_lifetime(t *testing.T) {
+	var got *Authorization
+	s := httptest.NewServer(Middleware(SigAuthHandlerOption{
+		SecretFinder: finderForTest,
+		MaxLifetime:  DefaultMaxLifetime,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = AuthorizationFromContext(r.Context())
+	})))
+	defer s.Close()
+
+	client := &http.Client{
+		Transport: &Transport{AccessKey: _key, Secret: _secret, Lifetime: 1500 * time.Millisecond},
+	}
+
+	res, err := client.Get(s.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, got.Timestamp+2, got.Expires)
+}